@@ -70,7 +70,7 @@ func (b *buildFile) Start() error {
 	b.config.Image = b.image
 
 	// Create the container and start it
-	container, err := env.ContainerCreate(b.tags, b.config)
+	container, err := env.ContainerCreate(b.tags, b.config, "")
 	if err != nil {
 		return err
 	}
@@ -155,6 +155,85 @@ func (b *buildFile) CmdEnv(args string) error {
 	return nil
 }
 
+// LABEL key=value [key=value...]
+func (b *buildFile) CmdLabel(args string) error {
+	words, err := splitQuoted(args)
+	if err != nil {
+		return err
+	}
+
+	if len(words) == 0 {
+		return fmt.Errorf("LABEL requires at least one key=value")
+	}
+
+	// Like ENV, a single "LABEL key value" is also accepted
+	if len(words) == 2 && !strings.Contains(words[0], "=") {
+		words = []string{words[0] + "=" + words[1]}
+	}
+
+	if b.config.Labels == nil {
+		b.config.Labels = make(map[string]string)
+	}
+
+	for _, word := range words {
+		parts := strings.SplitN(word, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("Invalid LABEL format: %s", word)
+		}
+
+		value, err := b.ReplaceEnvMatches(parts[1])
+		if err != nil {
+			return err
+		}
+
+		b.config.Labels[parts[0]] = value
+	}
+
+	return nil
+}
+
+// Split args on whitespace, keeping double quoted strings together and
+// removing the quotes.
+func splitQuoted(args string) ([]string, error) {
+	var words []string
+	var cur []rune
+
+	inWord, inQuote, escaped := false, false, false
+
+	for _, r := range args {
+		switch {
+		case escaped:
+			cur = append(cur, r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == '"':
+			inQuote = !inQuote
+			inWord = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if inWord {
+				words = append(words, string(cur))
+				cur = cur[:0]
+				inWord = false
+			}
+		default:
+			cur = append(cur, r)
+			inWord = true
+		}
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("Unterminated quote in: %s", args)
+	}
+
+	if inWord {
+		words = append(words, string(cur))
+	}
+
+	return words, nil
+}
+
 func (b *buildFile) CmdCmd(args string) error {
 	var cmd []string
 
@@ -175,7 +254,10 @@ func (b *buildFile) CmdExpose(args string) error {
 
 // SERVICE NAME PORT [VERSION]
 func (b *buildFile) CmdService(args string) error {
-	parts := strings.Split(args, " ")
+	parts := strings.Fields(args)
+	if len(parts) < 2 {
+		return fmt.Errorf("Invalid SERVICE format, expected NAME PORT [VERSION]")
+	}
 	name := parts[0]
 	p, _ := strconv.ParseUint(parts[1], 10, 16)
 	port := uint16(p)
@@ -435,7 +517,7 @@ func (b *buildFile) Build(context string) error {
 	}
 
 	if b.image != "" && b.outImage != "" {
-		img, err := b.container.Commit("", "", nil, b.squash, true)
+		img, err := b.container.Commit("", "", nil, b.squash, true, false)
 
 		if err != nil {
 			return err
@@ -449,8 +531,14 @@ func (b *buildFile) Build(context string) error {
 
 		repo, tag := env.ParseRepositoryTag(b.outImage)
 
-		ts.Add(repo, tag, img.ID)
-		ts.Flush()
+		err = ts.Update(func(ts *env.TagStore) error {
+			ts.Add(repo, tag, img.ID)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
 
 		fmt.Fprintf(b.out, "Built %s successfully\n", b.outImage)
 		return nil
@@ -483,7 +571,7 @@ func (b *buildFile) BuildTar(tar string) error {
 	}
 
 	if b.outImage != "" {
-		img, err := b.container.Commit("", "", nil, b.squash, true)
+		img, err := b.container.Commit("", "", nil, b.squash, true, false)
 
 		if err != nil {
 			return err
@@ -497,8 +585,14 @@ func (b *buildFile) BuildTar(tar string) error {
 
 		repo, tag := env.ParseRepositoryTag(b.outImage)
 
-		ts.Add(repo, tag, img.ID)
-		ts.Flush()
+		err = ts.Update(func(ts *env.TagStore) error {
+			ts.Add(repo, tag, img.ID)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
 
 		fmt.Fprintf(b.out, "Built %s successfully\n", b.outImage)
 		return nil
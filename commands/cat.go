@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vektra/components/app"
+)
+
+type catOptions struct{}
+
+func init() {
+	app.AddCommand("cat", "Print files from an image", "", &catOptions{})
+}
+
+func (co *catOptions) Usage() string {
+	return "<repo:tag|id> <file>..."
+}
+
+func (co *catOptions) Execute(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("Specify an image and at least one file\n")
+	}
+
+	view, err := imageView(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args[1:] {
+		f, err := view.Open(name)
+
+		if err != nil {
+			return fmt.Errorf("Unable to read %s: %s\n", name, err)
+		}
+
+		_, err = io.Copy(os.Stdout, f)
+		f.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -5,30 +5,28 @@ import (
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
-	"github.com/vektra/container/utils"
 )
 
 type commitOptions struct {
 	Author  string `long:"author" description:"Who is creating this image?"`
 	Comment string `long:"comment" description:"Any comment?"`
 	Squash  bool   `short:"s" description:"Make a squashfs based image"`
+	Pause   bool   `short:"p" long:"pause" description:"Pause a running container while it is committed"`
 }
 
 func (co *commitOptions) Usage() string {
-	return "[OPTIONS] <id>"
+	return "[OPTIONS] <id|name> <repo:tag>"
 }
 
 func (co *commitOptions) Execute(args []string) error {
-	if err := app.CheckArity(1, 1, args); err != nil {
+	if err := app.CheckArity(2, 2, args); err != nil {
 		return err
 	}
 
-	id := utils.ExpandID(env.DIR, args[0])
-
-	cont, err := env.LoadContainer(env.DIR, id)
+	cont, err := env.ResolveContainer(args[0])
 
 	if err != nil {
-		return fmt.Errorf("Unable to load %s: %s\n", id, err)
+		return fmt.Errorf("Unable to load %s: %s\n", args[0], err)
 	}
 
 	ts, err := env.DefaultTagStore()
@@ -37,7 +35,7 @@ func (co *commitOptions) Execute(args []string) error {
 		return err
 	}
 
-	img, err := cont.Commit(co.Comment, co.Author, nil, co.Squash, false)
+	img, err := cont.Commit(co.Comment, co.Author, nil, co.Squash, false, co.Pause)
 
 	if err != nil {
 		return fmt.Errorf("Unable to create image: %s\n", err)
@@ -45,10 +43,10 @@ func (co *commitOptions) Execute(args []string) error {
 
 	repo, tag := env.ParseRepositoryTag(args[1])
 
-	ts.Add(repo, tag, img.ID)
-	ts.Flush()
-
-	return nil
+	return ts.Update(func(ts *env.TagStore) error {
+		ts.Add(repo, tag, img.ID)
+		return nil
+	})
 }
 
 func init() {
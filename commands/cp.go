@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type cpOptions struct{}
+
+func init() {
+	app.AddCommand("cp", "Copy files between the host and a container or image", "", &cpOptions{})
+}
+
+func (co *cpOptions) Usage() string {
+	return "<id|name|repo:tag>:/path <hostpath|->  |  <hostpath> <id|name>:/path"
+}
+
+// Split a container:/path argument. Host paths are returned with an
+// empty ref.
+func parseCopyArg(arg string) (string, string) {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+
+	if idx := strings.Index(arg, ":/"); idx > 0 {
+		return arg[:idx], arg[idx+1:]
+	}
+
+	return "", arg
+}
+
+func (co *cpOptions) Execute(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	srcRef, src := parseCopyArg(args[0])
+	dstRef, dst := parseCopyArg(args[1])
+
+	if (srcRef == "") == (dstRef == "") {
+		return fmt.Errorf("Exactly one of the paths must be in a container, like <id>:/path\n")
+	}
+
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return err
+	}
+
+	if dstRef != "" {
+		cont, err := env.ResolveContainer(dstRef)
+
+		if err != nil {
+			if _, ok := err.(*utils.NoSuchIDError); ok {
+				if _, ierr := ts.ResolveImage(dstRef); ierr == nil {
+					return fmt.Errorf("Images are read-only, copy into a container instead\n")
+				}
+			}
+			return err
+		}
+
+		if err := cont.CopyIn(ts, src, dst); err != nil {
+			return fmt.Errorf("Unable to copy %s into %s: %s\n", src, dstRef, err)
+		}
+
+		return nil
+	}
+
+	view, err := copyView(ts, srcRef)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := view.Stat(src); err != nil {
+		return fmt.Errorf("Unable to copy %s: %s\n", src, err)
+	}
+
+	name := path.Base(src)
+
+	if name == "/" {
+		return fmt.Errorf("Specify a path below / to copy\n")
+	}
+
+	if dst == "-" {
+		return view.WriteTar(os.Stdout, src, name)
+	}
+
+	dir := dst
+
+	if fi, err := os.Stat(dst); err != nil || !fi.IsDir() {
+		dir, name = path.Dir(dst), path.Base(dst)
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(view.WriteTar(pw, src, name))
+	}()
+
+	err = utils.Untar(pr, dir)
+	pr.Close()
+
+	if err != nil {
+		return fmt.Errorf("Unable to copy %s to %s: %s\n", src, dst, err)
+	}
+
+	return nil
+}
+
+// Returns the view of the container or image named by ref
+func copyView(ts *env.TagStore, ref string) (*env.MergedView, error) {
+	cont, err := env.ResolveContainer(ref)
+
+	if err == nil {
+		return cont.View(ts)
+	}
+
+	if _, ok := err.(*utils.NoSuchIDError); !ok {
+		return nil, err
+	}
+
+	img, ierr := ts.ResolveImage(ref)
+
+	if ierr != nil {
+		return nil, fmt.Errorf("No container or image named '%s'\n", ref)
+	}
+
+	return img.View()
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type dfOptions struct{}
+
+func init() {
+	app.AddCommand("df", "Show disk usage of images, containers and volumes", "", &dfOptions{})
+}
+
+func (do *dfOptions) Usage() string {
+	return ""
+}
+
+func (do *dfOptions) Execute(args []string) error {
+	if err := app.CheckArity(0, 0, args); err != nil {
+		return err
+	}
+
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return err
+	}
+
+	ts.FillSizes()
+
+	conts, err := env.LoadContainers(env.DIR)
+
+	if err != nil {
+		return err
+	}
+
+	reach := ts.Reachable(conts)
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+
+	var ids []string
+
+	for id := range ts.Entries {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	var imgTotal, imgReclaim int64
+
+	fmt.Fprintf(w, "IMAGE\tREPO\tUNIQUE\tSHARED\tVIRTUAL\n")
+
+	for _, id := range ids {
+		img := ts.Entries[id]
+
+		repo, tag := ts.Find(id)
+		name := "<none>"
+		if repo != "" {
+			name = repo + ":" + tag
+		}
+
+		virt := img.VirtualSize()
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", utils.TruncateID(id), name,
+			utils.HumanSize(img.Size), utils.HumanSize(virt-img.Size), utils.HumanSize(virt))
+
+		imgTotal += img.Size
+
+		if !reach[id] {
+			imgReclaim += img.Size
+		}
+	}
+
+	w.Flush()
+	fmt.Println()
+
+	var contTotal, contReclaim int64
+
+	fmt.Fprintf(w, "CONTAINER\tIMAGE\tRW SIZE\tSTATUS\n")
+
+	for _, cont := range conts {
+		sz := cont.RWSize()
+
+		status := "stopped"
+		if cont.IsRunning() {
+			status = "running"
+		} else {
+			contReclaim += sz
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", utils.TruncateID(cont.ID),
+			utils.TruncateID(cont.Image), utils.HumanSize(sz), status)
+
+		contTotal += sz
+	}
+
+	w.Flush()
+	fmt.Println()
+
+	users := env.VolumeUsers(conts)
+
+	var volTotal, volReclaim int64
+
+	vols, _ := ioutil.ReadDir(path.Join(env.DIR, "volumes"))
+
+	fmt.Fprintf(w, "VOLUME\tSIZE\tCONTAINERS\n")
+
+	for _, vol := range vols {
+		sz, _ := utils.TreeSize(path.Join(env.DIR, "volumes", vol.Name()))
+
+		fmt.Fprintf(w, "%s\t%s\t%d\n", vol.Name(), utils.HumanSize(sz), len(users[vol.Name()]))
+
+		volTotal += sz
+
+		if len(users[vol.Name()]) == 0 {
+			volReclaim += sz
+		}
+	}
+
+	w.Flush()
+	fmt.Println()
+
+	fmt.Fprintf(w, "TYPE\tTOTAL\tRECLAIMABLE\n")
+	fmt.Fprintf(w, "Images\t%s\t%s\n", utils.HumanSize(imgTotal), utils.HumanSize(imgReclaim))
+	fmt.Fprintf(w, "Containers\t%s\t%s\n", utils.HumanSize(contTotal), utils.HumanSize(contReclaim))
+	fmt.Fprintf(w, "Volumes\t%s\t%s\n", utils.HumanSize(volTotal), utils.HumanSize(volReclaim))
+	w.Flush()
+
+	return nil
+}
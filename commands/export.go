@@ -107,7 +107,7 @@ func (eo *exportOptions) Execute(args []string) error {
 	curTagData, err := ioutil.ReadFile(path.Join(dir, "repositories"))
 
 	if err != nil {
-		e.tout = &env.TagStore{"", e.ents, make(map[string]env.Repository)}
+		e.tout = &env.TagStore{Entries: e.ents, Repositories: make(map[string]env.Repository)}
 	} else {
 		err = json.Unmarshal(curTagData, &e.tout)
 
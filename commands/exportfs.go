@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+)
+
+type exportFsOptions struct {
+	Output string `short:"o" long:"output" description:"Write the tar to this file instead of stdout"`
+}
+
+func init() {
+	app.AddCommand("export-fs", "Export the filesystem of a container as a tar", "", &exportFsOptions{})
+}
+
+func (eo *exportFsOptions) Usage() string {
+	return "[OPTIONS] <id|name|repo:tag>"
+}
+
+func (eo *exportFsOptions) Execute(args []string) error {
+	if err := app.CheckArity(1, 1, args); err != nil {
+		return err
+	}
+
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return err
+	}
+
+	view, err := copyView(ts, args[0])
+
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+
+	if eo.Output != "" {
+		f, err := os.Create(eo.Output)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		out = f
+	}
+
+	if err := view.WriteTar(out, "/", "."); err != nil {
+		if eo.Output != "" {
+			os.Remove(eo.Output)
+		}
+		return fmt.Errorf("Unable to export %s: %s\n", args[0], err)
+	}
+
+	return nil
+}
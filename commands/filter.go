@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vektra/container/env"
+)
+
+// Parsed --filter key=value arguments. Values given for the same key are
+// or'd together, different keys are and'd. Label filters are the exception,
+// every one of them has to match.
+type filterArgs map[string][]string
+
+func parseFilterArgs(specs []string) (filterArgs, error) {
+	args := make(filterArgs)
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid filter '%s', expected key=value\n", spec)
+		}
+
+		key := strings.ToLower(parts[0])
+		args[key] = append(args[key], parts[1])
+	}
+
+	return args, nil
+}
+
+func (args filterArgs) has(key string) bool {
+	_, ok := args[key]
+	return ok
+}
+
+// Resolve an image reference given to a filter into an image
+func filterImage(ts *env.TagStore, ref string) (*env.Image, error) {
+	img, err := ts.ResolveImage(ref)
+
+	if err != nil {
+		return nil, fmt.Errorf("Unknown image '%s' in filter: %s\n", ref, err)
+	}
+
+	return img, nil
+}
+
+// Resolve a container name or id prefix given to a filter among conts
+func filterContainer(conts []*env.Container, ref string) (*env.Container, error) {
+	var found *env.Container
+
+	for _, cont := range conts {
+		if cont.Name == ref {
+			return cont, nil
+		}
+	}
+
+	for _, cont := range conts {
+		if strings.HasPrefix(cont.ID, ref) {
+			if found != nil {
+				return nil, fmt.Errorf("Ambiguous container '%s' in filter\n", ref)
+			}
+			found = cont
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("Unknown container '%s' in filter\n", ref)
+	}
+
+	return found, nil
+}
+
+type containerPredicate func(*env.Container) bool
+
+// Build a predicate matching the containers selected by args
+func containerFilter(args filterArgs, ts *env.TagStore, conts []*env.Container) (containerPredicate, error) {
+	var preds [][]containerPredicate
+
+	for key, values := range args {
+		var alts []containerPredicate
+
+		for _, val := range values {
+			val := val
+
+			switch key {
+			case "label":
+				preds = append(preds, []containerPredicate{func(c *env.Container) bool {
+					return env.MatchLabel(c.Labels(), val)
+				}})
+			case "status":
+				switch val {
+				case "running":
+					alts = append(alts, func(c *env.Container) bool { return c.IsRunning() })
+				case "paused":
+					alts = append(alts, func(c *env.Container) bool { return c.IsRunning() && c.State.Paused })
+				case "exited":
+					alts = append(alts, func(c *env.Container) bool { return !c.IsRunning() })
+				default:
+					return nil, fmt.Errorf("Invalid status '%s', use running, paused or exited\n", val)
+				}
+			case "image":
+				img, err := filterImage(ts, val)
+				if err != nil {
+					return nil, err
+				}
+				alts = append(alts, func(c *env.Container) bool { return c.Image == img.ID })
+			case "ancestor":
+				img, err := filterImage(ts, val)
+				if err != nil {
+					return nil, err
+				}
+				alts = append(alts, func(c *env.Container) bool {
+					ci, ok := ts.Entries[c.Image]
+					return ok && ci.HasAncestor(img.ID)
+				})
+			case "before", "since":
+				ref, err := filterContainer(conts, val)
+				if err != nil {
+					return nil, err
+				}
+				if key == "before" {
+					alts = append(alts, func(c *env.Container) bool { return c.Created.Before(ref.Created) })
+				} else {
+					alts = append(alts, func(c *env.Container) bool { return c.Created.After(ref.Created) })
+				}
+			default:
+				return nil, fmt.Errorf("Unsupported container filter '%s'\n", key)
+			}
+		}
+
+		if len(alts) > 0 {
+			preds = append(preds, alts)
+		}
+	}
+
+	return func(c *env.Container) bool {
+		for _, alts := range preds {
+			matched := false
+
+			for _, pred := range alts {
+				if pred(c) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+type imagePredicate func(*env.Image) bool
+
+// Build a predicate matching the images selected by args. The dangling
+// filter is handled by the caller since it changes which images are listed.
+func imageFilter(args filterArgs, ts *env.TagStore) (imagePredicate, error) {
+	var preds [][]imagePredicate
+
+	for key, values := range args {
+		var alts []imagePredicate
+
+		for _, val := range values {
+			val := val
+
+			switch key {
+			case "dangling":
+				if val != "true" && val != "false" {
+					return nil, fmt.Errorf("Invalid dangling value '%s', use true or false\n", val)
+				}
+				continue
+			case "label":
+				preds = append(preds, []imagePredicate{func(i *env.Image) bool {
+					return env.MatchLabel(i.Labels(), val)
+				}})
+			case "ancestor":
+				img, err := filterImage(ts, val)
+				if err != nil {
+					return nil, err
+				}
+				alts = append(alts, func(i *env.Image) bool { return i.HasAncestor(img.ID) })
+			case "before", "since":
+				ref, err := filterImage(ts, val)
+				if err != nil {
+					return nil, err
+				}
+				if key == "before" {
+					alts = append(alts, func(i *env.Image) bool { return i.Created.Before(ref.Created) })
+				} else {
+					alts = append(alts, func(i *env.Image) bool { return i.Created.After(ref.Created) })
+				}
+			default:
+				return nil, fmt.Errorf("Unsupported image filter '%s'\n", key)
+			}
+		}
+
+		if len(alts) > 0 {
+			preds = append(preds, alts)
+		}
+	}
+
+	return func(i *env.Image) bool {
+		for _, alts := range preds {
+			matched := false
+
+			for _, pred := range alts {
+				if pred(i) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
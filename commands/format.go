@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/vektra/container/utils"
+)
+
+var formatFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"trunc": utils.TruncateID,
+	"join":  strings.Join,
+	"human": utils.HumanSize,
+}
+
+// Print rows, which must be a slice, according to a --format value. "json"
+// writes the whole slice as a json array, anything else is used as a
+// text/template executed once per row.
+func printFormatted(format string, rows interface{}) error {
+	v := reflect.ValueOf(rows)
+
+	if format == "json" {
+		// No rows is [], not null
+		if v.IsNil() {
+			rows = reflect.MakeSlice(v.Type(), 0, 0).Interface()
+		}
+
+		data, err := json.MarshalIndent(rows, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		os.Stdout.Write(data)
+		os.Stdout.Write([]byte("\n"))
+
+		return nil
+	}
+
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(format)
+
+	if err != nil {
+		return fmt.Errorf("Invalid format template: %s\n", err)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if err := tmpl.Execute(os.Stdout, v.Index(i).Interface()); err != nil {
+			return err
+		}
+
+		os.Stdout.Write([]byte("\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+)
+
+type fsckOptions struct {
+	Repair bool `long:"repair" description:"Fix or quarantine broken entries"`
+}
+
+func init() {
+	app.AddCommand("fsck", "Check the image and container store for problems", "", &fsckOptions{})
+}
+
+func (fo *fsckOptions) Usage() string {
+	return "[OPTIONS]"
+}
+
+func (fo *fsckOptions) Execute(args []string) error {
+	if err := app.CheckArity(0, 0, args); err != nil {
+		return err
+	}
+
+	problems, err := env.Fsck()
+
+	if err != nil {
+		return err
+	}
+
+	unfixed := 0
+
+	for _, p := range problems {
+		fmt.Printf("%s\n", p)
+
+		if !fo.Repair {
+			unfixed++
+			continue
+		}
+
+		if !p.Repairable() {
+			fmt.Printf("  unable to repair automatically\n")
+			unfixed++
+			continue
+		}
+
+		if err := p.Repair(); err != nil {
+			fmt.Printf("  repair failed: %s\n", err)
+			unfixed++
+		} else {
+			fmt.Printf("  repaired\n")
+		}
+	}
+
+	if unfixed > 0 {
+		return fmt.Errorf("%d problems found\n", unfixed)
+	}
+
+	return nil
+}
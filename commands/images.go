@@ -12,7 +12,17 @@ import (
 )
 
 type imagesOptions struct {
-	Verbose bool `short:"v" description:"Show more details"`
+	Verbose bool     `short:"v" description:"Show more details"`
+	Filter  []string `short:"f" long:"filter" description:"Filter output (dangling, label, ancestor, before, since)"`
+	Format  string   `long:"format" description:"Print using json or a Go template"`
+	Quiet   bool     `short:"q" description:"Only print image ids"`
+}
+
+// An image along with one of the repo:tags that points to it
+type imageRow struct {
+	*env.Image
+	Repo string
+	Tag  string
 }
 
 func (io *imagesOptions) Usage() string {
@@ -27,7 +37,7 @@ func (io *imagesOptions) Execute(args []string) error {
 	var repoDir string
 	if len(args) > 0 {
 		repoDir = args[0]
-		fmt.Printf("Loading tag store from: %s\n", repoDir)
+		fmt.Fprintf(os.Stderr, "Loading tag store from: %s\n", repoDir)
 	} else {
 		repoDir = env.DIR
 	}
@@ -39,11 +49,16 @@ func (io *imagesOptions) Execute(args []string) error {
 		return fmt.Errorf("No images: %s\n", err)
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
-	if io.Verbose {
-		fmt.Fprintf(w, "REPO\tTAG\tID\tPARENT\tCREATED\n")
-	} else {
-		fmt.Fprintf(w, "REPO\tTAG\tID\n")
+	filters, err := parseFilterArgs(io.Filter)
+
+	if err != nil {
+		return err
+	}
+
+	match, err := imageFilter(filters, ts)
+
+	if err != nil {
+		return err
 	}
 
 	var repos []string
@@ -54,6 +69,35 @@ func (io *imagesOptions) Execute(args []string) error {
 
 	sort.Strings(repos)
 
+	var rows []imageRow
+
+	dangling := false
+	for _, val := range filters["dangling"] {
+		dangling = dangling || val == "true"
+	}
+
+	if dangling {
+		reach := ts.Reachable(nil)
+
+		var ids []string
+
+		for id := range ts.Entries {
+			if !reach[id] {
+				ids = append(ids, id)
+			}
+		}
+
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			if match(ts.Entries[id]) {
+				rows = append(rows, imageRow{ts.Entries[id], "<none>", "<none>"})
+			}
+		}
+
+		repos = nil
+	}
+
 	for _, repo := range repos {
 		tags := ts.Repositories[repo]
 
@@ -66,19 +110,52 @@ func (io *imagesOptions) Execute(args []string) error {
 		sort.Strings(stags)
 
 		for _, tag := range stags {
-			id := tags[tag]
-
-			if io.Verbose {
-				img := ts.Entries[id]
-				if img == nil {
-					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", repo, tag, utils.TruncateID(id), "?", "?")
-				} else {
-					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", repo, tag, utils.TruncateID(id),
-						utils.TruncateID(img.Parent), img.Created)
-				}
+			img := ts.Entries[tags[tag]]
+			if img == nil {
+				img = &env.Image{ID: tags[tag]}
+			}
+
+			if match(img) {
+				rows = append(rows, imageRow{img, repo, tag})
+			}
+		}
+	}
+
+	if io.Quiet {
+		for _, row := range rows {
+			fmt.Printf("%s\n", row.ID)
+		}
+		return nil
+	}
+
+	if io.Format != "" {
+		return printFormatted(io.Format, rows)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	if io.Verbose {
+		fmt.Fprintf(w, "REPO\tTAG\tID\tPARENT\tCREATED\tSIZE\n")
+	} else {
+		fmt.Fprintf(w, "REPO\tTAG\tID\n")
+	}
+
+	for _, row := range rows {
+		repo, tag, id := row.Repo, row.Tag, row.ID
+
+		if io.Verbose {
+			img := ts.Entries[id]
+			if img == nil {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", repo, tag, utils.TruncateID(id), "?", "?", "?")
 			} else {
-				fmt.Fprintf(w, "%s\t%s\t%s\n", repo, tag, utils.TruncateID(id))
+				size := "?"
+				if img.Size > 0 {
+					size = utils.HumanSize(img.Size)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", repo, tag, utils.TruncateID(id),
+					utils.TruncateID(img.Parent), img.Created, size)
 			}
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", repo, tag, utils.TruncateID(id))
 		}
 	}
 
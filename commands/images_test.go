@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Runs fn and returns what it wrote to stdout
+func captureStdout(t *testing.T, fn func() error) string {
+	r, w, err := os.Pipe()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+
+	out := make(chan []byte)
+
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		out <- data
+	}()
+
+	err = fn()
+
+	os.Stdout = stdout
+	w.Close()
+
+	data := <-out
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestImagesFormatJSONWithRepoDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "images-test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(root)
+
+	id := strings.Repeat("ab", 32)
+
+	if err := os.MkdirAll(path.Join(root, "graph", id), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"repositories":                 `{"Repositories":{"base":{"latest":"` + id + `"}}}`,
+		path.Join("graph", id, "json"): `{"id":"` + id + `","created":"2014-01-01T00:00:00Z"}`,
+	}
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(path.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := captureStdout(t, func() error {
+		return (&imagesOptions{Format: "json"}).Execute([]string{root})
+	})
+
+	var rows []map[string]interface{}
+
+	if err := json.Unmarshal([]byte(out), &rows); err != nil {
+		t.Fatalf("images --format json %s is not json: %s\n%s", root, err, out)
+	}
+
+	if len(rows) != 1 || rows[0]["Repo"] != "base" || rows[0]["Tag"] != "latest" {
+		t.Errorf("Unexpected rows: %s", out)
+	}
+
+	out = captureStdout(t, func() error {
+		return (&imagesOptions{Quiet: true}).Execute([]string{root})
+	})
+
+	if out != id+"\n" {
+		t.Errorf("images -q %s printed %q, want only the id", root, out)
+	}
+}
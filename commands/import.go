@@ -14,9 +14,9 @@ import (
 )
 
 type Importer struct {
-	dir     string
-	tags    *env.TagStore
-	sysTags *env.TagStore
+	dir      string
+	tags     *env.TagStore
+	imported []string
 }
 
 type importOptions struct{}
@@ -32,7 +32,7 @@ func (io *importOptions) Execute(args []string) error {
 
 	dir := args[0]
 
-	i := &Importer{dir, nil, nil}
+	i := &Importer{dir: dir}
 
 	name, tag := env.ParseRepositoryTag(args[1])
 
@@ -66,38 +66,34 @@ func (io *importOptions) Execute(args []string) error {
 		return fmt.Errorf("No tag named %s found\n", tag)
 	}
 
-	i.sysTags = &env.TagStore{}
-	sysPath := path.Join(env.DIR, "repositories")
-
-	sysData, err := ioutil.ReadFile(sysPath)
-
-	if err != nil {
-		i.sysTags.Repositories = make(map[string]env.Repository)
-	} else {
-		err = json.Unmarshal(sysData, &i.sysTags)
-
-		if err != nil {
-			return err
-		}
-	}
-
 	i.importLayer(hash)
 
-	sysData, err = json.Marshal(i.sysTags)
+	sysTags, err := env.ReadRepoFile(path.Join(env.DIR, "repositories"))
 
 	if err != nil {
 		return err
 	}
 
-	ioutil.WriteFile(sysPath, sysData, 0644)
-
-	return nil
+	return i.saveTags(sysTags)
 }
 
 func init() {
 	app.AddCommand("import", "Import an image from disk", "", &importOptions{})
 }
 
+// Copy the tags for every imported layer into the system tag store
+func (i *Importer) saveTags(sysTags *env.TagStore) error {
+	fmt.Printf("Importing tags...\n")
+
+	return sysTags.Update(func(ts *env.TagStore) error {
+		for _, hash := range i.imported {
+			i.tags.CopyTo(ts, hash, false)
+		}
+
+		return nil
+	})
+}
+
 func (i *Importer) alreadyExists(hash string) bool {
 	outPath := path.Join(env.DIR, "graph", hash)
 
@@ -159,18 +155,28 @@ func (i *Importer) extract(hash, tmpPath string) *env.Image {
 		panic(err)
 	}
 
-	utils.Run("cp", path.Join(tmpPath, "metadata.js"), path.Join(outPath, "json"))
-
 	utils.Run("tar", "--numeric-owner", "-f", path.Join(tmpPath, "data.tar.bz2"),
 		"-C", path.Join(outPath, "layer"), "-xj")
 
+	img.Size = env.LayerSizeAt(outPath)
+
+	jsonData, err = json.Marshal(img)
+
+	if err != nil {
+		panic(err)
+	}
+
+	err = ioutil.WriteFile(path.Join(outPath, "json"), jsonData, 0644)
+
+	if err != nil {
+		panic(err)
+	}
+
 	utils.Run("cp", path.Join(tmpPath, "data.tar.bz2"), path.Join(outPath, "layer.tar.bz2"))
 
 	os.RemoveAll(tmpPath)
 
-	fmt.Printf("Importing tags...\n")
-
-	i.tags.CopyTo(i.sysTags, hash, false)
+	i.imported = append(i.imported, hash)
 
 	return img
 }
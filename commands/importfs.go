@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type importFsOptions struct {
+	Changes []string `short:"c" long:"change" description:"Apply a Dockerfile instruction to the image config (CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, SERVICE, VOLUME)"`
+	Author  string   `long:"author" description:"Who is creating this image?"`
+	Comment string   `short:"m" long:"comment" description:"Any comment?"`
+	Squash  bool     `short:"s" description:"Make a squashfs based image"`
+}
+
+// Instructions which only change the config and so can be used with
+// --change, and the fewest arguments each takes
+var changeInstructions = map[string]int{
+	"cmd":        1,
+	"entrypoint": 1,
+	"env":        2,
+	"expose":     1,
+	"label":      1,
+	"service":    2,
+	"volume":     1,
+}
+
+func init() {
+	app.AddCommand("import-fs", "Create a base image from a rootfs tarball", "", &importFsOptions{})
+}
+
+func (fo *importFsOptions) Usage() string {
+	return "[OPTIONS] <tar|-> <repo:tag>"
+}
+
+func (fo *importFsOptions) Execute(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	b := &buildFile{
+		config: &env.Config{
+			Env: []string{"HOME=/", "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"},
+		},
+		out: ioutil.Discard,
+	}
+
+	for _, change := range fo.Changes {
+		if err := b.applyChange(change); err != nil {
+			return err
+		}
+	}
+
+	var archive = os.Stdin
+
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		archive = f
+	}
+
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return err
+	}
+
+	img, err := env.ImportRootfs(archive, fo.Comment, fo.Author, b.config, fo.Squash)
+
+	if err != nil {
+		return fmt.Errorf("Unable to import %s: %s\n", args[0], err)
+	}
+
+	repo, tag := env.ParseRepositoryTag(args[1])
+
+	err = ts.Update(func(ts *env.TagStore) error {
+		ts.Add(repo, tag, img.ID)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %s as %s\n", utils.TruncateID(img.ID), args[1])
+
+	return nil
+}
+
+// Apply a single Dockerfile instruction which only touches the config,
+// like "CMD /bin/bash".
+func (b *buildFile) applyChange(line string) error {
+	tmp := strings.SplitN(strings.TrimSpace(line), " ", 2)
+
+	if len(tmp) != 2 {
+		return fmt.Errorf("Invalid change '%s', expected INSTRUCTION arguments\n", line)
+	}
+
+	instruction := strings.ToLower(tmp[0])
+	minArgs, ok := changeInstructions[instruction]
+
+	if !ok {
+		return fmt.Errorf("%s can't be used with --change\n", strings.ToUpper(instruction))
+	}
+
+	if len(strings.Fields(tmp[1])) < minArgs {
+		return fmt.Errorf("%s needs at least %d arguments: %s\n", strings.ToUpper(instruction), minArgs, line)
+	}
+
+	method, _ := reflect.TypeOf(b).MethodByName("Cmd" + strings.ToUpper(instruction[:1]) + instruction[1:])
+
+	ret := method.Func.Call([]reflect.Value{reflect.ValueOf(b), reflect.ValueOf(strings.TrimSpace(tmp[1]))})[0].Interface()
+
+	if ret != nil {
+		return ret.(error)
+	}
+
+	return nil
+}
@@ -3,21 +3,33 @@ package commands
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
+	"sort"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
 	"github.com/vektra/container/utils"
 )
 
-type inspectOptions struct{}
+type inspectOptions struct {
+	Type string `short:"t" long:"type" description:"Only look for a container, image or volume"`
+}
+
+// What inspect shows for an image
+type imageDetails struct {
+	*env.Image
+	Parents     []string `json:"parents,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	VirtualSize int64    `json:"virtual_size"`
+}
 
 func init() {
-	app.AddCommand("inspect", "Display details about a container", "", &inspectOptions{})
+	app.AddCommand("inspect", "Display details about a container, image or volume", "", &inspectOptions{})
 }
 
 func (io *inspectOptions) Usage() string {
-	return "<id>"
+	return "[OPTIONS] <id|name|repo:tag|volume>"
 }
 
 func (io *inspectOptions) Execute(args []string) error {
@@ -25,16 +37,64 @@ func (io *inspectOptions) Execute(args []string) error {
 		return err
 	}
 
-	id := utils.ExpandID(env.DIR, args[0])
+	ref := args[0]
+
+	var obj interface{}
+
+	switch io.Type {
+	case "":
+		cont, err := env.ResolveContainer(ref)
+
+		if err == nil {
+			obj = cont
+			break
+		}
+
+		if _, ok := err.(*utils.NoSuchIDError); !ok {
+			return err
+		}
+
+		if obj, err = inspectImage(ref); err == nil {
+			break
+		}
+
+		if obj, err = inspectVolume(ref); err != nil {
+			return fmt.Errorf("No container, image or volume named '%s'\n", ref)
+		}
+	case "container":
+		cont, err := env.ResolveContainer(ref)
 
-	cont, err := env.LoadContainer(env.DIR, id)
+		if err != nil {
+			return err
+		}
+
+		obj = cont
+	case "image":
+		img, err := inspectImage(ref)
+
+		if err != nil {
+			return err
+		}
+
+		obj = img
+	case "volume":
+		vol, err := inspectVolume(ref)
+
+		if err != nil {
+			return err
+		}
+
+		obj = vol
+	default:
+		return fmt.Errorf("Invalid type '%s', use container, image or volume\n", io.Type)
+	}
+
+	data, err := json.Marshal(obj)
 
 	if err != nil {
 		return err
 	}
 
-	data, err := json.Marshal(cont)
-
 	var out bytes.Buffer
 
 	json.Indent(&out, data, "", "  ")
@@ -44,3 +104,37 @@ func (io *inspectOptions) Execute(args []string) error {
 
 	return nil
 }
+
+func inspectImage(ref string) (*imageDetails, error) {
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := ts.ResolveImage(ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ts.FillSizes()
+
+	details := &imageDetails{
+		Image:       img,
+		Parents:     img.ParentIDs(),
+		VirtualSize: img.VirtualSize(),
+	}
+
+	for repo, tags := range ts.Repositories {
+		for tag, id := range tags {
+			if id == img.ID {
+				details.Tags = append(details.Tags, repo+":"+tag)
+			}
+		}
+	}
+
+	sort.Strings(details.Tags)
+
+	return details, nil
+}
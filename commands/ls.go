@@ -1,22 +1,24 @@
 package commands
 
 import (
+	"fmt"
 	"os"
-	"os/exec"
-	"path"
+	"text/tabwriter"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
 )
 
-type lsOptions struct{}
+type lsOptions struct {
+	Long bool `short:"l" description:"Show mode, size and modification time"`
+}
 
 func init() {
-	app.AddCommand("ls", "List files in a container", "", &lsOptions{})
+	app.AddCommand("ls", "List files in an image", "", &lsOptions{})
 }
 
 func (lo *lsOptions) Usage() string {
-	return "<repo:tag> [dir]"
+	return "[OPTIONS] <repo:tag|id> [dir]"
 }
 
 func (lo *lsOptions) Execute(args []string) error {
@@ -24,28 +26,79 @@ func (lo *lsOptions) Execute(args []string) error {
 		return err
 	}
 
-	repo := args[0]
-
-	dir := "."
+	dir := "/"
 
 	if len(args) > 1 {
 		dir = args[1]
 	}
 
-	tags, err := env.DefaultTagStore()
+	view, err := imageView(args[0])
 
 	if err != nil {
 		return err
 	}
 
-	img, err := tags.LookupImage(repo)
+	fi, err := view.Stat(dir)
 
 	if err != nil {
-		return err
+		return fmt.Errorf("Unable to list %s: %s\n", dir, err)
+	}
+
+	ents := []os.FileInfo{fi}
+
+	if fi.IsDir() {
+		ents, err = view.ReadDir(dir)
+
+		if err != nil {
+			return fmt.Errorf("Unable to list %s: %s\n", dir, err)
+		}
+	}
+
+	if !lo.Long {
+		for _, ent := range ents {
+			fmt.Printf("%s\n", ent.Name())
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 1, 2, ' ', tabwriter.AlignRight)
+
+	for _, ent := range ents {
+		name := ent.Name()
+
+		if ent.Mode()&os.ModeSymlink != 0 {
+			pth := dir
+
+			if fi.IsDir() {
+				pth = dir + "/" + name
+			}
+
+			if target, err := view.Readlink(pth); err == nil {
+				name += " -> " + target
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t %s\t %s\n", ent.Mode(), ent.Size(), ent.ModTime().Format("2006-01-02 15:04"), name)
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+// Returns a merged view of the layers of the image named by ref
+func imageView(ref string) (*env.MergedView, error) {
+	tags, err := env.DefaultTagStore()
+
+	if err != nil {
+		return nil, err
 	}
 
-	e := exec.Command("ls", path.Join(env.DIR, "graph", img.ID, "layer", dir))
-	e.Stdout = os.Stdout
+	img, err := tags.ResolveImage(ref)
+
+	if err != nil {
+		return nil, err
+	}
 
-	return e.Run()
+	return img.View()
 }
@@ -2,9 +2,7 @@ package commands
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
@@ -18,6 +16,16 @@ func nukeImage(no *nukeOptions, id string) error {
 		return err
 	}
 
+	if err := ts.Lock(); err != nil {
+		return err
+	}
+
+	defer ts.Unlock()
+
+	if err := ts.Reload(); err != nil {
+		return err
+	}
+
 	img, err := ts.LookupImage(id)
 
 	if err == nil {
@@ -62,7 +70,7 @@ type nukeOptions struct {
 }
 
 func (no *nukeOptions) Usage() string {
-	return "[OPTIONS] <repo:tag> | <id>"
+	return "[OPTIONS] <repo:tag> | <id|name>"
 }
 
 func init() {
@@ -74,31 +82,24 @@ func (no *nukeOptions) Execute(args []string) error {
 		return err
 	}
 
-	id := utils.ExpandID(env.DIR, args[0])
-
-	root := path.Join(env.DIR, "containers", id)
-
-	_, err := os.Stat(root)
+	cont, err := env.ResolveContainer(args[0])
 
 	if err != nil {
-		// Look for an image instead
-		return nukeImage(no, id)
+		if _, ok := err.(*utils.NoSuchIDError); ok {
+			// Look for an image instead
+			return nukeImage(no, args[0])
+		}
+		return err
 	}
 
-	_, err = ioutil.ReadFile(path.Join(root, "running"))
-
-	if err == nil {
+	if cont.IsRunning() {
 		fmt.Printf("Cowardly refusing to nuke running container\n")
 		os.Exit(1)
 	}
 
-	err = os.RemoveAll(root)
-
-	if err != nil {
-		return err
-	}
+	cont.Remove()
 
-	fmt.Printf("Removed %s\n", id)
+	fmt.Printf("Removed %s\n", cont.ID)
 
 	return nil
 }
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+)
+
+type pauseOptions struct{}
+
+func init() {
+	app.AddCommand("pause", "Pause all processes in one or more containers", "", &pauseOptions{})
+}
+
+func (po *pauseOptions) Usage() string {
+	return "<id|name>..."
+}
+
+func (po *pauseOptions) Execute(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Specify the containers to pause\n")
+	}
+
+	for _, ref := range args {
+		cont, err := env.ResolveContainer(ref)
+
+		if err != nil {
+			return fmt.Errorf("Unable to find container: %s\n", ref)
+		}
+
+		if err := cont.Pause(); err != nil {
+			return fmt.Errorf("Unable to pause %s: %s\n", ref, err)
+		}
+
+		fmt.Println(ref)
+	}
+
+	return nil
+}
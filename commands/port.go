@@ -2,15 +2,25 @@ package commands
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
-	"github.com/vektra/container/utils"
 )
 
 type portOptions struct {
-	TCP string `short:"t" description:"Print the tcp port for a containers port"`
-	UDP string `short:"u" description:"Print the udp port for a containers port"`
+	TCP    string `short:"t" description:"Print the tcp port for a containers port"`
+	UDP    string `short:"u" description:"Print the udp port for a containers port"`
+	Format string `long:"format" description:"Print using json or a Go template"`
+	Quiet  bool   `short:"q" description:"Only print the host ports"`
+}
+
+// One entry of a container's env.PortMapping
+type portRow struct {
+	Proto   string
+	Private string
+	Public  string
 }
 
 func init() {
@@ -18,7 +28,7 @@ func init() {
 }
 
 func (po *portOptions) Usage() string {
-	return "[OPTIONS] <id>"
+	return "[OPTIONS] <id|name>"
 }
 
 func (po *portOptions) Execute(args []string) error {
@@ -26,12 +36,10 @@ func (po *portOptions) Execute(args []string) error {
 		return err
 	}
 
-	id := utils.ExpandID(env.DIR, args[0])
-
-	cont, err := env.LoadContainer(env.DIR, id)
+	cont, err := env.ResolveContainer(args[0])
 
 	if err != nil {
-		return fmt.Errorf("Error loading conatiner %s: %s\n", id, err)
+		return fmt.Errorf("Error loading container %s: %s\n", args[0], err)
 	}
 
 	if po.TCP != "" {
@@ -58,12 +66,36 @@ func (po *portOptions) Execute(args []string) error {
 		return nil
 	}
 
-	for c, h := range cont.NetworkSettings.PortMapping["Tcp"] {
-		fmt.Printf("tcp %s -> tcp %s\n", c, h)
+	var rows []portRow
+
+	for _, proto := range []string{"Tcp", "Udp"} {
+		var private []string
+
+		for c := range cont.NetworkSettings.PortMapping[proto] {
+			private = append(private, c)
+		}
+
+		sort.Strings(private)
+
+		for _, c := range private {
+			h := cont.NetworkSettings.PortMapping[proto][c]
+			rows = append(rows, portRow{strings.ToLower(proto), c, h})
+		}
+	}
+
+	if po.Quiet {
+		for _, row := range rows {
+			fmt.Printf("%s\n", row.Public)
+		}
+		return nil
+	}
+
+	if po.Format != "" {
+		return printFormatted(po.Format, rows)
 	}
 
-	for c, h := range cont.NetworkSettings.PortMapping["Udp"] {
-		fmt.Printf("udp %s -> udp %s\n", c, h)
+	for _, row := range rows {
+		fmt.Printf("%s %s -> %s %s\n", row.Proto, row.Private, row.Proto, row.Public)
 	}
 
 	return nil
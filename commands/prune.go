@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type pruneOptions struct {
+	DryRun    bool     `short:"n" long:"dry-run" description:"Only show what would be removed"`
+	OlderThan string   `long:"older-than" description:"Only remove stopped containers, images and temp dirs older than this" default:"24h"`
+	Filter    []string `short:"f" long:"filter" description:"Only remove containers and images matching a filter (label)"`
+}
+
+func init() {
+	app.AddCommand("prune", "Remove unused images, containers and temp dirs", "", &pruneOptions{})
+}
+
+func (po *pruneOptions) Usage() string {
+	return "[OPTIONS]"
+}
+
+func (po *pruneOptions) Execute(args []string) error {
+	if err := app.CheckArity(0, 0, args); err != nil {
+		return err
+	}
+
+	age, err := time.ParseDuration(po.OlderThan)
+
+	if err != nil {
+		return fmt.Errorf("Invalid duration '%s': %s\n", po.OlderThan, err)
+	}
+
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return err
+	}
+
+	filters, err := parseFilterArgs(po.Filter)
+
+	if err != nil {
+		return err
+	}
+
+	for key := range filters {
+		if key != "label" {
+			return fmt.Errorf("Unsupported prune filter '%s'\n", key)
+		}
+	}
+
+	report, err := env.PlanPrune(ts, age, filters["label"])
+
+	if err != nil {
+		return err
+	}
+
+	verb := "Removing"
+	if po.DryRun {
+		verb = "Would remove"
+	}
+
+	for _, cont := range report.Containers {
+		fmt.Printf("%s container %s\n", verb, utils.TruncateID(cont.ID))
+	}
+
+	for _, img := range report.Images {
+		fmt.Printf("%s image %s\n", verb, utils.TruncateID(img.ID))
+	}
+
+	for _, dir := range report.TempDirs {
+		fmt.Printf("%s %s\n", verb, dir)
+	}
+
+	if po.DryRun {
+		fmt.Printf("Would reclaim %s\n", utils.HumanSize(report.Size))
+		return nil
+	}
+
+	if err := report.Apply(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Reclaimed %s\n", utils.HumanSize(report.Size))
+
+	return nil
+}
@@ -2,14 +2,14 @@ package commands
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
 	"sort"
 	"text/tabwriter"
+	"time"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
 )
 
 type Containers []*env.Container
@@ -21,7 +21,13 @@ func (c Containers) Less(i, j int) bool {
 	return c[i].Created.After(c[j].Created)
 }
 
-type psOptions struct{}
+type psOptions struct {
+	All     bool     `short:"a" description:"Show stopped containers too"`
+	Filter  []string `short:"f" long:"filter" description:"Filter output (status, label, image, ancestor, before, since)"`
+	Format  string   `long:"format" description:"Print using json or a Go template"`
+	Quiet   bool     `short:"q" description:"Only print container ids"`
+	NoTrunc bool     `long:"no-trunc" description:"Don't truncate ids and commands"`
+}
 
 func init() {
 	app.AddCommand("ps", "List containers", "", &psOptions{})
@@ -32,7 +38,7 @@ func (po *psOptions) Execute(args []string) error {
 		return err
 	}
 
-	dir, err := ioutil.ReadDir(path.Join(env.DIR, "containers"))
+	conts, err := env.LoadContainers(env.DIR)
 
 	if err != nil {
 		return err
@@ -40,41 +46,78 @@ func (po *psOptions) Execute(args []string) error {
 
 	ts, err := env.DefaultTagStore()
 
-	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
-	fmt.Fprintf(w, "  ID\tREPO\tCREATED\n")
+	if err != nil {
+		return err
+	}
+
+	filters, err := parseFilterArgs(po.Filter)
+
+	if err != nil {
+		return err
+	}
+
+	// Only running containers are shown unless asked for
+	if !po.All && !filters.has("status") {
+		filters["status"] = []string{"running"}
+	}
+
+	match, err := containerFilter(filters, ts, conts)
+
+	if err != nil {
+		return err
+	}
 
 	var cs Containers
 
-	for _, f := range dir {
-		id := f.Name()
+	for _, cont := range conts {
+		if match(cont) {
+			cs = append(cs, cont)
+		}
+	}
+
+	sort.Sort(cs)
 
-		cont, err := env.LoadContainer(env.DIR, id)
-		if err != nil {
-			continue
+	if po.Quiet {
+		for _, cont := range cs {
+			fmt.Printf("%s\n", cont.ID)
 		}
+		return nil
+	}
 
-		cs = append(cs, cont)
+	if po.Format != "" {
+		return printFormatted(po.Format, cs)
 	}
 
-	sort.Sort(cs)
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "ID\tIMAGE\tCOMMAND\tCREATED\tSTATUS\tPORTS\tNAMES\n")
 
 	for _, cont := range cs {
-		repo, tag := ts.Find(cont.Image)
-
-		state := "  "
+		id := cont.ID
+		image := cont.Image
+		command := cont.Command()
+
+		if repo, tag := ts.Find(cont.Image); repo != "" {
+			image = repo + ":" + tag
+		} else if !po.NoTrunc {
+			image = utils.TruncateID(image)
+		}
 
-		_, err := os.Stat(cont.PathTo("running"))
+		if !po.NoTrunc {
+			id = utils.TruncateID(id)
 
-		if err == nil {
-			state = "* "
+			if len(command) > 20 {
+				command = command[:17] + "..."
+			}
 		}
 
-		if repo == "" {
-			fmt.Fprintf(w, "%s%s\t \t%s\n", state, cont.ID[0:12], cont.Created.String())
-		} else {
-			fmt.Fprintf(w, "%s%s\t%s:%s\t%s\n", state, cont.ID[0:12], repo, tag, cont.Created.String())
+		var ports string
+
+		if cont.NetworkSettings != nil && cont.IsRunning() {
+			ports = cont.NetworkSettings.PortsString()
 		}
 
+		fmt.Fprintf(w, "%s\t%s\t\"%s\"\t%s ago\t%s\t%s\t%s\n", id, image, command,
+			env.HumanDuration(time.Now().Sub(cont.Created)), cont.State.String(), ports, cont.Name)
 	}
 
 	w.Flush()
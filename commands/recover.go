@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type recoverOptions struct{}
+
+func init() {
+	app.AddCommand("recover", "Clean up after containers whose process has died", "", &recoverOptions{})
+}
+
+func (ro *recoverOptions) Usage() string {
+	return ""
+}
+
+func (ro *recoverOptions) Execute(args []string) error {
+	if err := app.CheckArity(0, 0, args); err != nil {
+		return err
+	}
+
+	conts, err := env.Recover()
+
+	if err != nil {
+		return err
+	}
+
+	for _, cont := range conts {
+		fmt.Printf("Recovered %s\n", utils.TruncateID(cont.ID))
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+)
+
+type renameOptions struct{}
+
+func init() {
+	app.AddCommand("rename", "Rename a container", "", &renameOptions{})
+}
+
+func (ro *renameOptions) Usage() string {
+	return "<id|name> <new-name>"
+}
+
+func (ro *renameOptions) Execute(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	cont, err := env.ResolveContainer(args[0])
+
+	if err != nil {
+		return fmt.Errorf("Unable to load %s: %s\n", args[0], err)
+	}
+
+	if err := cont.Rename(args[1]); err != nil {
+		return fmt.Errorf("Unable to rename %s: %s\n", args[0], err)
+	}
+
+	return nil
+}
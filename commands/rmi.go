@@ -27,9 +27,11 @@ func (ro *rmiOptions) Execute(args []string) error {
 		return err
 	}
 
-	if !ts.RemoveByPrefix(args[0]) {
-		fmt.Println("Unable to remove image.")
-	}
+	return ts.Update(func(ts *env.TagStore) error {
+		if !ts.RemoveByPrefix(args[0]) {
+			fmt.Println("Unable to remove image.")
+		}
 
-	return ts.Flush()
+		return nil
+	})
 }
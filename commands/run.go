@@ -4,11 +4,11 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
 )
 
 var forwardSignals = []os.Signal{
@@ -19,19 +19,30 @@ var forwardSignals = []os.Signal{
 }
 
 type runOptions struct {
+	Name       string   `long:"name" description:"Assign a name to the container"`
 	User       string   `short:"u" description:"Username or UID"`
 	Memory     int64    `short:"m" description:"Memory limit (in bytes)"`
+	MemSwap    string   `long:"memory-swap" description:"Limit for memory plus swap, like 2g, or -1 for unlimited swap"`
 	CIDFile    string   `long:"cidfile" description:"Write the container ID to a file"`
 	Network    bool     `short:"n" description:"Enable networking" default:"true"`
 	CPU        int64    `short:"c" description:"CPU shares (relative weight)"`
+	Cpus       float64  `long:"cpus" description:"Number of CPUs the container may use, like 1.5"`
+	CpusetCpus string   `long:"cpuset-cpus" description:"CPUs the container may run on, like 0-2,4"`
+	PidsLimit  int64    `long:"pids-limit" description:"Maximum number of processes"`
+	BlkioWt    uint16   `long:"blkio-weight" description:"Block IO weight, between 10 and 1000"`
+	DevReadBps []string `long:"device-read-bps" description:"Limit the read rate of a device (/dev/sda:10m)"`
 	Ports      []string `short:"p" description:"Export a port"`
 	Env        []string `short:"e" description:"Set environment variables"`
 	EnvDir     string   `long:"envdir" description:"Load env vars from an envdir"`
 	DNS        []string `long:"dns" description:"Set custom dns servers"`
 	Volumes    []string `short:"v" description:"Bind mount volumes"`
+	VolsFrom   string   `long:"volumes-from" description:"Mount the volumes of another container (<container>[:ro])"`
+	ReadOnly   bool     `long:"read-only" description:"Mount the container's root filesystem read-only"`
+	Tmpfs      []string `long:"tmpfs" description:"Mount a tmpfs (/path[:size=..,mode=..])"`
+	Labels     []string `short:"l" long:"label" description:"Set a label on the container (key=value)"`
 	Save       bool     `long:"save" description:"Save the container when it exits"`
 	EntryPoint string   `long:"entrypoint" description:"Set the default entrypoint"`
-	Hook       string   `long:"hook" description:"Execute this command once the container is booted"`
+	Hook       string   `long:"hook" description:"Execute this command with the event and id when the container is booted (started) or OOM killed (oom)"`
 	Tool       bool     `short:"t" description"Run a provided tool"`
 }
 
@@ -44,7 +55,7 @@ func (ro *runOptions) Usage() string {
 }
 
 func (ro *runOptions) Execute(args []string) error {
-	capa := &env.Capabilities{}
+	capa := env.DetectCapabilities()
 
 	config, hostcfg, err := ParseRun(ro, args, capa)
 	if err != nil {
@@ -66,7 +77,7 @@ func (ro *runOptions) Execute(args []string) error {
 		return err
 	}
 
-	container, err := env.ContainerCreate(tags, config)
+	container, err := env.ContainerCreate(tags, config, ro.Name)
 
 	if err != nil {
 		return fmt.Errorf("Unable to create container: %s\n", err)
@@ -93,28 +104,102 @@ func (ro *runOptions) Execute(args []string) error {
 
 	container.Wait(hostcfg)
 
+	if container.State.OOMKilled {
+		limit := ""
+
+		if config.Memory > 0 {
+			limit = fmt.Sprintf(" (limit %s)", utils.HumanSize(config.Memory))
+		}
+
+		fmt.Fprintf(os.Stderr, "== Killed: %s ran out of memory%s, peak usage %s\n",
+			utils.TruncateID(container.ID), limit, utils.HumanSize(int64(container.State.MemoryPeak)))
+	}
+
 	return nil
 }
 
 func ParseRun(ro *runOptions, args []string, capabilities *env.Capabilities) (*env.Config, *env.HostConfig, error) {
 	if capabilities != nil && ro.Memory > 0 && !capabilities.MemoryLimit {
-		//fmt.Fprintf(stdout, "WARNING: Your kernel does not support memory limit capabilities. Limitation discarded.\n")
+		fmt.Fprintf(os.Stderr, "WARNING: Your kernel does not support memory limit capabilities. Limitation discarded.\n")
 		ro.Memory = 0
 	}
 
 	var binds []string
 	volumes := map[string]struct{}{}
+	mounts := map[string]bool{}
 
 	// add any bind targets to the list of container volumes
-	for _, bind := range ro.Volumes {
-		arr := strings.Split(bind, ":")
-		if len(arr) > 1 && arr[1][0:1] != "@" {
-			dstDir := arr[1]
-			volumes[dstDir] = struct{}{}
-			binds = append(binds, bind)
+	for _, spec := range ro.Volumes {
+		bind, err := env.ParseBind(spec)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if mounts[bind.DstPath] {
+			return nil, nil, fmt.Errorf("Duplicate mount point: %s", bind.DstPath)
+		}
+
+		mounts[bind.DstPath] = true
+
+		if bind.Volume == "" {
+			volumes[bind.DstPath] = struct{}{}
+			binds = append(binds, spec)
 		} else {
-			volumes[bind] = struct{}{}
+			volumes[spec] = struct{}{}
+		}
+	}
+
+	var memSwap int64
+
+	if ro.MemSwap == "-1" {
+		memSwap = -1
+	} else if ro.MemSwap != "" {
+		var err error
+
+		if memSwap, err = utils.RAMInBytes(ro.MemSwap); err != nil {
+			return nil, nil, fmt.Errorf("Invalid memory-swap value %s", ro.MemSwap)
+		}
+	}
+
+	var readBps []env.ThrottleDevice
+
+	for _, spec := range ro.DevReadBps {
+		dev, err := env.ParseThrottleDevice(spec)
+
+		if err != nil {
+			return nil, nil, err
 		}
+
+		readBps = append(readBps, dev)
+	}
+
+	labels, err := env.ParseLabels(ro.Labels)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tmpfs map[string]string
+
+	for _, spec := range ro.Tmpfs {
+		dst, opts, err := env.ParseTmpfs(spec)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if mounts[dst] {
+			return nil, nil, fmt.Errorf("Duplicate mount point: %s", dst)
+		}
+
+		mounts[dst] = true
+
+		if tmpfs == nil {
+			tmpfs = make(map[string]string)
+		}
+
+		tmpfs[dst] = opts
 	}
 
 	parsedArgs := args
@@ -154,7 +239,13 @@ func ParseRun(ro *runOptions, args []string, capabilities *env.Capabilities) (*e
 		NetworkDisabled: !ro.Network,
 		OpenStdin:       true,
 		Memory:          ro.Memory,
+		MemorySwap:      memSwap,
 		CpuShares:       ro.CPU,
+		Cpus:            ro.Cpus,
+		CpusetCpus:      ro.CpusetCpus,
+		PidsLimit:       ro.PidsLimit,
+		BlkioWeight:     ro.BlkioWt,
+		DeviceReadBps:   readBps,
 		AttachStdin:     true,
 		AttachStdout:    true,
 		AttachStderr:    true,
@@ -163,8 +254,9 @@ func ParseRun(ro *runOptions, args []string, capabilities *env.Capabilities) (*e
 		Dns:             ro.DNS,
 		Image:           image,
 		Volumes:         volumes,
-		VolumesFrom:     "",
+		VolumesFrom:     ro.VolsFrom,
 		Entrypoint:      entrypoint,
+		Labels:          labels,
 	}
 
 	hostConfig := &env.HostConfig{
@@ -173,10 +265,14 @@ func ParseRun(ro *runOptions, args []string, capabilities *env.Capabilities) (*e
 		Save:            ro.Save,
 		EnvDir:          ro.EnvDir,
 		Hook:            ro.Hook,
+		ReadonlyRootfs:  ro.ReadOnly,
+		Tmpfs:           tmpfs,
 	}
 
 	if capabilities != nil && ro.Memory > 0 && !capabilities.SwapLimit {
-		//fmt.Fprintf(stdout, "WARNING: Your kernel does not support swap limit capabilities. Limitation discarded.\n")
+		if memSwap > 0 {
+			fmt.Fprintf(os.Stderr, "WARNING: Your kernel does not support swap limit capabilities. Limitation discarded.\n")
+		}
 		config.MemorySwap = -1
 	}
 
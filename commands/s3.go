@@ -142,7 +142,7 @@ func (so *s3Options) Execute(args []string) error {
 		return err
 	}
 
-	i := &Importer{tags: ts, sysTags: dts}
+	i := &Importer{tags: ts}
 
 	if !so.Force {
 		if i.alreadyExists(id) {
@@ -154,7 +154,9 @@ func (so *s3Options) Execute(args []string) error {
 
 	err = i.download(buk, id)
 
-	dts.Flush()
+	if serr := i.saveTags(dts); serr != nil && err == nil {
+		err = serr
+	}
 
 	return err
 }
@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type squashOptions struct {
+	Author  string `long:"author" description:"Who is creating this image?"`
+	Comment string `long:"comment" description:"Any comment?"`
+	Squash  bool   `short:"s" description:"Make a squashfs based image"`
+}
+
+func init() {
+	app.AddCommand("squash", "Flatten an image and its parents into one layer", "", &squashOptions{})
+}
+
+func (so *squashOptions) Usage() string {
+	return "[OPTIONS] <image> <repo:tag>"
+}
+
+func (so *squashOptions) Execute(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	ts, err := env.DefaultTagStore()
+
+	if err != nil {
+		return err
+	}
+
+	img, err := ts.ResolveImage(args[0])
+
+	if err != nil {
+		return fmt.Errorf("Unable to find image %s: %s\n", args[0], err)
+	}
+
+	out, err := img.Squash(so.Comment, so.Author, so.Squash)
+
+	if err != nil {
+		return fmt.Errorf("Unable to squash image: %s\n", err)
+	}
+
+	repo, tag := env.ParseRepositoryTag(args[1])
+
+	err = ts.Update(func(ts *env.TagStore) error {
+		ts.Add(repo, tag, out.ID)
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Squashed %s into %s (%s)\n", args[0], args[1], utils.TruncateID(out.ID))
+
+	return nil
+}
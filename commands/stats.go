@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type statsOptions struct {
+	NoStream bool `long:"no-stream" description:"Print a single sample and exit"`
+	JSON     bool `long:"json" description:"Print each sample as a line of json"`
+}
+
+// A line of stats output
+type statsRow struct {
+	ID            string
+	Name          string
+	CPUPercent    float64
+	MemoryPercent float64
+	*env.Stats
+}
+
+func init() {
+	app.AddCommand("stats", "Show the resource usage of running containers", "", &statsOptions{})
+}
+
+func (so *statsOptions) Usage() string {
+	return "[OPTIONS] [<id|name>...]"
+}
+
+// The containers to sample, those given or else every running one
+func statsContainers(args []string) ([]*env.Container, error) {
+	if len(args) == 0 {
+		conts, err := env.LoadContainers(env.DIR)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var running []*env.Container
+
+		for _, cont := range conts {
+			if cont.IsRunning() {
+				running = append(running, cont)
+			}
+		}
+
+		return running, nil
+	}
+
+	var conts []*env.Container
+
+	for _, ref := range args {
+		cont, err := env.ResolveContainer(ref)
+
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find container: %s\n", ref)
+		}
+
+		if !cont.IsRunning() {
+			return nil, fmt.Errorf("Container %s is not running\n", ref)
+		}
+
+		conts = append(conts, cont)
+	}
+
+	return conts, nil
+}
+
+// Reads the stats of cont, or nil if it has stopped
+func sampleStats(cont *env.Container) (*env.Stats, error) {
+	s, err := cont.Stats()
+
+	if err != nil {
+		if !cont.IsRunning() {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("Unable to read the stats of %s: %s\n", utils.TruncateID(cont.ID), err)
+	}
+
+	return s, nil
+}
+
+func (so *statsOptions) Execute(args []string) error {
+	conts, err := statsContainers(args)
+
+	if err != nil {
+		return err
+	}
+
+	// CPU use is measured between two samples, so take one to start from
+	prev := make(map[string]*env.Stats)
+
+	for _, cont := range conts {
+		s, err := sampleStats(cont)
+
+		if err != nil {
+			return err
+		}
+
+		if s != nil {
+			prev[cont.ID] = s
+		}
+	}
+
+	for {
+		time.Sleep(time.Second)
+
+		var rows []*statsRow
+
+		for _, cont := range conts {
+			s, err := sampleStats(cont)
+
+			if err != nil {
+				return err
+			}
+
+			if s == nil {
+				continue
+			}
+
+			rows = append(rows, &statsRow{
+				ID:            cont.ID,
+				Name:          cont.Name,
+				CPUPercent:    s.CPUPercent(prev[cont.ID]),
+				MemoryPercent: s.MemoryPercent(),
+				Stats:         s,
+			})
+
+			prev[cont.ID] = s
+		}
+
+		if err := so.print(rows); err != nil {
+			return err
+		}
+
+		if so.NoStream || (len(args) > 0 && len(rows) == 0) {
+			return nil
+		}
+
+		if len(args) == 0 {
+			if conts, err = statsContainers(nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (so *statsOptions) print(rows []*statsRow) error {
+	if so.JSON {
+		enc := json.NewEncoder(os.Stdout)
+
+		for _, row := range rows {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if !so.NoStream {
+		// Clear the screen so the table updates in place
+		fmt.Print("\033[2J\033[H")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "CONTAINER\tNAME\tCPU %%\tMEM USAGE / LIMIT\tMEM %%\tNET I/O\tBLOCK I/O\tPIDS\n")
+
+	for _, row := range rows {
+		limit, memPercent := "-", "-"
+
+		if row.MemoryLimit > 0 {
+			limit = utils.HumanSize(int64(row.MemoryLimit))
+			memPercent = fmt.Sprintf("%.2f%%", row.MemoryPercent)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%s\t%s / %s\t%s / %s\t%d\n",
+			utils.TruncateID(row.ID),
+			row.Name,
+			row.CPUPercent,
+			utils.HumanSize(int64(row.MemoryUsage)), limit,
+			memPercent,
+			utils.HumanSize(int64(row.NetRx)), utils.HumanSize(int64(row.NetTx)),
+			utils.HumanSize(int64(row.BlockRead)), utils.HumanSize(int64(row.BlockWrite)),
+			row.Pids)
+	}
+
+	w.Flush()
+
+	return nil
+}
@@ -49,23 +49,25 @@ func (to *tagOptions) Execute(args []string) error {
 
 		repo, tag := env.ParseRepositoryTag(args[0])
 
-		ts.RemoveTag(repo, tag)
-	} else {
-		if len(args) < 2 {
-			return fmt.Errorf("Specify a repo:tag and id to add\n")
-		}
-		repo, tag := env.ParseRepositoryTag(args[0])
+		return ts.Update(func(ts *env.TagStore) error {
+			ts.RemoveTag(repo, tag)
+			return nil
+		})
+	}
 
-		id, ok := env.SafelyExpandImageID(args[1])
+	if len(args) < 2 {
+		return fmt.Errorf("Specify a repo:tag and id to add\n")
+	}
+	repo, tag := env.ParseRepositoryTag(args[0])
 
-		if !ok {
-			return fmt.Errorf("Unable to find image matching '%s'\n", args[1])
-		}
+	id, ok := env.SafelyExpandImageID(args[1])
 
-		ts.Add(repo, tag, id)
+	if !ok {
+		return fmt.Errorf("Unable to find image matching '%s'\n", args[1])
 	}
 
-	ts.Flush()
-
-	return nil
+	return ts.Update(func(ts *env.TagStore) error {
+		ts.Add(repo, tag, id)
+		return nil
+	})
 }
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+)
+
+type topOptions struct{}
+
+// What ps is run with when no options are given. It must list every
+// process on the host, the ones in the container are picked out afterwards.
+var defaultPsArgs = []string{"-eo", "user,pid,ppid,pcpu,pmem,rss,stat,time,args"}
+
+func init() {
+	app.AddCommand("top", "List the processes running in a container", "", &topOptions{})
+}
+
+func (to *topOptions) Usage() string {
+	return "<id|name> [ps options]"
+}
+
+func (to *topOptions) Execute(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Specify the container to list the processes of\n")
+	}
+
+	cont, err := env.ResolveContainer(args[0])
+
+	if err != nil {
+		return fmt.Errorf("Unable to find container: %s\n", args[0])
+	}
+
+	if !cont.IsRunning() {
+		return fmt.Errorf("Container %s is not running\n", args[0])
+	}
+
+	procs, err := cont.Processes()
+
+	if err != nil {
+		return fmt.Errorf("Unable to list processes: %s\n", err)
+	}
+
+	cpids := make(map[int]int)
+
+	for _, proc := range procs {
+		cpids[proc.Pid] = proc.ContainerPid
+	}
+
+	psArgs := defaultPsArgs
+
+	if len(args) > 1 {
+		psArgs = args[1:]
+	}
+
+	out, err := exec.Command("ps", psArgs...).Output()
+
+	if err != nil {
+		return fmt.Errorf("Error running ps: %s\n", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	header := strings.Fields(lines[0])
+
+	pidCol := -1
+
+	for i, name := range header {
+		if name == "PID" {
+			pidCol = i
+		}
+	}
+
+	if pidCol < 0 {
+		return fmt.Errorf("The ps output has no PID column, include pid in the options\n")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 8, 1, 3, ' ', 0)
+
+	// The container pid goes right after the host one
+	fmt.Fprintf(w, "%s\t%s\t%s\n",
+		strings.Join(header[:pidCol+1], "\t"), "CPID", strings.Join(header[pidCol+1:], "\t"))
+
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+
+		if len(fields) <= pidCol {
+			continue
+		}
+
+		// The last column, usually the command, may contain spaces
+		if len(fields) > len(header) {
+			fields = append(fields[:len(header)-1], strings.Join(fields[len(header)-1:], " "))
+		}
+
+		pid, err := strconv.Atoi(fields[pidCol])
+
+		if err != nil {
+			continue
+		}
+
+		cpid, ok := cpids[pid]
+
+		if !ok {
+			continue
+		}
+
+		shown := "-"
+
+		if cpid > 0 {
+			shown = strconv.Itoa(cpid)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			strings.Join(fields[:pidCol+1], "\t"), shown, strings.Join(fields[pidCol+1:], "\t"))
+	}
+
+	w.Flush()
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+)
+
+type unpauseOptions struct{}
+
+func init() {
+	app.AddCommand("unpause", "Unpause all processes in one or more containers", "", &unpauseOptions{})
+}
+
+func (uo *unpauseOptions) Usage() string {
+	return "<id|name>..."
+}
+
+func (uo *unpauseOptions) Execute(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("Specify the containers to unpause\n")
+	}
+
+	for _, ref := range args {
+		cont, err := env.ResolveContainer(ref)
+
+		if err != nil {
+			return fmt.Errorf("Unable to find container: %s\n", ref)
+		}
+
+		if err := cont.Unpause(); err != nil {
+			return fmt.Errorf("Unable to unpause %s: %s\n", ref, err)
+		}
+
+		fmt.Println(ref)
+	}
+
+	return nil
+}
@@ -0,0 +1,434 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vektra/components/app"
+	"github.com/vektra/container/env"
+	"github.com/vektra/container/utils"
+)
+
+type volumeOptions struct {
+	Driver  string   `short:"d" long:"driver" description:"Driver for volume create (local, loop, tmpfs)" default:"local"`
+	Options []string `short:"o" long:"opt" description:"Driver option for volume create (key=value)"`
+	Labels  []string `short:"l" long:"label" description:"Set a label for volume create (key=value)"`
+	Force   bool     `long:"force" description:"Remove a volume stopped containers use, or back up, restore or clone one in use"`
+	Filter  []string `short:"f" long:"filter" description:"Filter volume ls output (label, driver, dangling)"`
+	Format  string   `long:"format" description:"Print using json or a Go template"`
+	Quiet   bool     `short:"q" description:"Only print volume names"`
+}
+
+// What volume inspect and ls show for a volume
+type volumeDetails struct {
+	*env.VolumeInfo
+	Path   string
+	Size   int64
+	UsedBy []string
+}
+
+func init() {
+	app.AddCommand("volume", "Manage named volumes",
+		"create <name>, inspect <name>..., ls, rm <name>..., backup <name> <file|->, "+
+			"restore <file|-> <name> or clone <src> <dst>", &volumeOptions{})
+}
+
+func (vo *volumeOptions) Usage() string {
+	return "[OPTIONS] create|inspect|ls|rm|backup|restore|clone ..."
+}
+
+func (vo *volumeOptions) Execute(args []string) error {
+	if len(args) == 0 {
+		return vo.list(nil)
+	}
+
+	switch args[0] {
+	case "create":
+		return vo.create(args[1:])
+	case "inspect":
+		return vo.inspect(args[1:])
+	case "ls", "list":
+		return vo.list(args[1:])
+	case "rm", "remove":
+		return vo.remove(args[1:])
+	case "backup":
+		return vo.backup(args[1:])
+	case "restore":
+		return vo.restore(args[1:])
+	case "clone":
+		return vo.clone(args[1:])
+	}
+
+	return fmt.Errorf("Unknown volume command '%s', use create, inspect, ls, rm, backup, restore or clone\n", args[0])
+}
+
+func (vo *volumeOptions) create(args []string) error {
+	if err := app.CheckArity(1, 1, args); err != nil {
+		return err
+	}
+
+	opts, err := env.ParseLabels(vo.Options)
+
+	if err != nil {
+		return err
+	}
+
+	labels, err := env.ParseLabels(vo.Labels)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := env.CreateVolume(args[0], vo.Driver, opts, labels); err != nil {
+		return fmt.Errorf("Unable to create volume: %s\n", err)
+	}
+
+	fmt.Printf("%s\n", args[0])
+
+	return nil
+}
+
+// Gather the size and users of each volume
+func describeVolumes(vols []*env.VolumeInfo) ([]*volumeDetails, error) {
+	conts, err := env.LoadContainers(env.DIR)
+
+	if err != nil {
+		return nil, err
+	}
+
+	users := env.VolumeUsers(conts)
+
+	var out []*volumeDetails
+
+	for _, vol := range vols {
+		d := &volumeDetails{VolumeInfo: vol, Path: vol.Path(), Size: vol.Size()}
+
+		for _, cont := range users[vol.Name] {
+			d.UsedBy = append(d.UsedBy, utils.TruncateID(cont.ID))
+		}
+
+		out = append(out, d)
+	}
+
+	return out, nil
+}
+
+func inspectVolume(name string) (*volumeDetails, error) {
+	vol, err := env.LoadVolumeInfo(name)
+
+	if err != nil {
+		return nil, fmt.Errorf("No volume named '%s'\n", name)
+	}
+
+	details, err := describeVolumes([]*env.VolumeInfo{vol})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return details[0], nil
+}
+
+func (vo *volumeOptions) inspect(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Specify at least one volume to inspect\n")
+	}
+
+	var details []*volumeDetails
+
+	for _, name := range args {
+		d, err := inspectVolume(name)
+
+		if err != nil {
+			return err
+		}
+
+		details = append(details, d)
+	}
+
+	if vo.Format != "" && vo.Format != "json" {
+		return printFormatted(vo.Format, details)
+	}
+
+	data, err := json.Marshal(details)
+
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+
+	json.Indent(&out, data, "", "  ")
+
+	out.WriteTo(os.Stdout)
+	os.Stdout.Write([]byte("\n"))
+
+	return nil
+}
+
+func (vo *volumeOptions) list(args []string) error {
+	if err := app.CheckArity(0, 0, args); err != nil {
+		return err
+	}
+
+	filters, err := parseFilterArgs(vo.Filter)
+
+	if err != nil {
+		return err
+	}
+
+	for key, values := range filters {
+		switch key {
+		case "label", "driver":
+		case "dangling":
+			for _, val := range values {
+				if val != "true" && val != "false" {
+					return fmt.Errorf("Invalid dangling value '%s', use true or false\n", val)
+				}
+			}
+		default:
+			return fmt.Errorf("Unsupported volume filter '%s'\n", key)
+		}
+	}
+
+	vols, err := env.ListVolumes()
+
+	if err != nil {
+		return fmt.Errorf("Error reading volumes: %s\n", err)
+	}
+
+	all, err := describeVolumes(vols)
+
+	if err != nil {
+		return err
+	}
+
+	var rows []*volumeDetails
+
+	for _, vol := range all {
+		if !env.MatchLabels(vol.Labels, filters["label"]) {
+			continue
+		}
+
+		if drivers, ok := filters["driver"]; ok && !contains(drivers, vol.Driver) {
+			continue
+		}
+
+		if dangling, ok := filters["dangling"]; ok {
+			if contains(dangling, "true") != (len(vol.UsedBy) == 0) {
+				continue
+			}
+		}
+
+		rows = append(rows, vol)
+	}
+
+	if vo.Quiet {
+		for _, row := range rows {
+			fmt.Printf("%s\n", row.Name)
+		}
+		return nil
+	}
+
+	if vo.Format != "" {
+		return printFormatted(vo.Format, rows)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 20, 1, 3, ' ', 0)
+	fmt.Fprintf(w, "NAME\tDRIVER\tSIZE\tCONTAINERS\n")
+
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.Name, row.Driver, utils.HumanSize(row.Size), strings.Join(row.UsedBy, ","))
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+func (vo *volumeOptions) remove(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Specify at least one volume to remove\n")
+	}
+
+	conts, err := env.LoadContainers(env.DIR)
+
+	if err != nil {
+		return err
+	}
+
+	users := env.VolumeUsers(conts)
+
+	for _, name := range args {
+		if _, err := env.LoadVolumeInfo(name); err != nil {
+			return fmt.Errorf("No volume to remove: %s\n", name)
+		}
+
+		for _, cont := range users[name] {
+			if cont.IsRunning() {
+				return fmt.Errorf("Volume %s is in use by running container %s\n", name, utils.TruncateID(cont.ID))
+			}
+
+			if !vo.Force {
+				return fmt.Errorf("Volume %s is used by container %s (use --force to remove anyway)\n",
+					name, utils.TruncateID(cont.ID))
+			}
+		}
+
+		if err := env.RemoveVolume(name); err != nil {
+			return fmt.Errorf("Unable to remove volume %s: %s\n", name, err)
+		}
+
+		fmt.Printf("%s\n", name)
+	}
+
+	return nil
+}
+
+// Refuse to work on a volume a running container has mounted, since its
+// data may change underneath, unless --force is given.
+func (vo *volumeOptions) checkNotRunning(name, action string) error {
+	if vo.Force {
+		return nil
+	}
+
+	conts, err := env.LoadContainers(env.DIR)
+
+	if err != nil {
+		return err
+	}
+
+	for _, cont := range env.VolumeUsers(conts)[name] {
+		if cont.IsRunning() {
+			return fmt.Errorf("Volume %s is in use by running container %s (use --force to %s anyway)\n",
+				name, utils.TruncateID(cont.ID), action)
+		}
+	}
+
+	return nil
+}
+
+func (vo *volumeOptions) backup(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	name, file := args[0], args[1]
+
+	vol, err := env.LoadVolumeInfo(name)
+
+	if err != nil {
+		return fmt.Errorf("No volume named '%s'\n", name)
+	}
+
+	if err := vo.checkNotRunning(name, "back it up"); err != nil {
+		return err
+	}
+
+	if file == "-" {
+		if err := vol.Backup(os.Stdout); err != nil {
+			return fmt.Errorf("Unable to back up volume %s: %s\n", name, err)
+		}
+		return nil
+	}
+
+	// Write next to the destination and rename into place so a failed
+	// backup never clobbers a good one
+	tmp := file + ".tmp"
+
+	f, err := os.Create(tmp)
+
+	if err != nil {
+		return err
+	}
+
+	err = vol.Backup(f)
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, file)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Unable to back up volume %s: %s\n", name, err)
+	}
+
+	return nil
+}
+
+func (vo *volumeOptions) restore(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	file, name := args[0], args[1]
+
+	if err := vo.checkNotRunning(name, "restore it"); err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+
+	if file != "-" {
+		f, err := os.Open(file)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		in = f
+	}
+
+	if _, err := env.RestoreVolume(in, name); err != nil {
+		return fmt.Errorf("Unable to restore volume %s: %s\n", name, err)
+	}
+
+	fmt.Printf("%s\n", name)
+
+	return nil
+}
+
+func (vo *volumeOptions) clone(args []string) error {
+	if err := app.CheckArity(2, 2, args); err != nil {
+		return err
+	}
+
+	src, dst := args[0], args[1]
+
+	if _, err := env.LoadVolumeInfo(src); err != nil {
+		return fmt.Errorf("No volume named '%s'\n", src)
+	}
+
+	if err := vo.checkNotRunning(src, "clone it"); err != nil {
+		return err
+	}
+
+	if _, err := env.CloneVolume(src, dst); err != nil {
+		return fmt.Errorf("Unable to clone volume %s: %s\n", src, err)
+	}
+
+	fmt.Printf("%s\n", dst)
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,66 +2,49 @@ package commands
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path"
 
 	"github.com/vektra/components/app"
 	"github.com/vektra/container/env"
 )
 
-type volumeOptions struct {
-	Remove string `short:"r" description:"Remove a named volume"`
-	Dir    string `short:"d" description:"Print the directory a volume is at"`
+// The command volume replaced, kept so scripts using its flags still work
+type volumesOptions struct {
+	Create string   `short:"c" long:"create" description:"Create a named volume, like volume create"`
+	Labels []string `short:"l" long:"label" description:"Set a label on a created volume (key=value)"`
+	Remove string   `short:"r" description:"Remove a named volume, like volume rm"`
+	Dir    string   `short:"d" description:"Print the directory a volume is at"`
+	Filter []string `short:"f" long:"filter" description:"Filter output (label, driver, dangling)"`
+	Format string   `long:"format" description:"Print using json or a Go template"`
+	Quiet  bool     `short:"q" description:"Only print volume names"`
 }
 
-var flRemove *string
-var flDir *string
-
 func init() {
-	app.AddCommand("volumes", "Disable and manipulate named volumes", "", &volumeOptions{})
+	app.AddCommand("volumes", "List named volumes", "See volume for everything else", &volumesOptions{})
 }
 
-func (vo *volumeOptions) Execute(args []string) error {
+func (vo *volumesOptions) Execute(args []string) error {
 	if err := app.CheckArity(0, 0, args); err != nil {
 		return err
 	}
 
-	if vo.Remove != "" {
-		pth := path.Join(env.DIR, "volumes", vo.Remove)
-
-		_, err := os.Stat(pth)
-
-		if err != nil {
-			return fmt.Errorf("No volume to remove: %s\n", vo.Remove)
-		}
+	if vo.Create != "" {
+		return (&volumeOptions{Driver: "local", Labels: vo.Labels}).create([]string{vo.Create})
+	}
 
-		os.RemoveAll(pth)
-		return nil
+	if vo.Remove != "" {
+		return (&volumeOptions{}).remove([]string{vo.Remove})
 	}
 
 	if vo.Dir != "" {
-		pth := path.Join(env.DIR, "volumes", vo.Dir)
-
-		_, err := os.Stat(pth)
+		vol, err := env.LoadVolumeInfo(vo.Dir)
 
 		if err != nil {
 			return fmt.Errorf("No volume: %s\n", vo.Dir)
 		}
 
-		fmt.Printf("%s\n", pth)
+		fmt.Printf("%s\n", vol.Path())
 		return nil
 	}
 
-	dirs, err := ioutil.ReadDir(path.Join(env.DIR, "volumes"))
-
-	if err != nil {
-		return fmt.Errorf("Error reading volumes: %s\n", err)
-	}
-
-	for _, d := range dirs {
-		fmt.Printf("%s\n", d.Name())
-	}
-
-	return nil
+	return (&volumeOptions{Filter: vo.Filter, Format: vo.Format, Quiet: vo.Quiet}).list(nil)
 }
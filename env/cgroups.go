@@ -0,0 +1,450 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/vektra/container/utils"
+)
+
+// ThrottleDevice limits the rate of IO to a block device
+type ThrottleDevice struct {
+	Path string
+	Rate int64 // bytes per second
+}
+
+// A cgroup key and the value lxc writes to it
+type cgroupSetting struct {
+	Key   string
+	Value string
+}
+
+// The cfs period used for Config.Cpus, 100ms
+const cpuPeriod = 100000
+
+var cpusetRegexp = regexp.MustCompile(`^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$`)
+
+// CgroupV2 reports whether resource limits go through the cgroup v2 unified
+// hierarchy. Hybrid systems, which still mount the v1 controllers, use v1.
+func CgroupV2() bool {
+	if _, err := utils.FindCgroupMountpoint("memory"); err == nil {
+		return false
+	}
+
+	if _, err := utils.FindCgroupMountpoint("cpu"); err == nil {
+		return false
+	}
+
+	_, err := utils.FindCgroup2Mountpoint()
+	return err == nil
+}
+
+// DetectCapabilities reports which resource limits the kernel supports
+func DetectCapabilities() *Capabilities {
+	caps := &Capabilities{CgroupV2: CgroupV2()}
+
+	if caps.CgroupV2 {
+		mnt, err := utils.FindCgroup2Mountpoint()
+
+		if err != nil {
+			return caps
+		}
+
+		data, _ := ioutil.ReadFile(path.Join(mnt, "cgroup.controllers"))
+		caps.MemoryLimit = strings.Contains(" "+string(data)+" ", " memory ")
+
+		// Only child cgroups have the memory files, and only when the
+		// kernel accounts swap
+		swap, _ := filepath.Glob(path.Join(mnt, "*", "memory.swap.max"))
+		caps.SwapLimit = caps.MemoryLimit && len(swap) > 0
+
+		return caps
+	}
+
+	if mnt, err := utils.FindCgroupMountpoint("memory"); err == nil {
+		_, err := os.Stat(path.Join(mnt, "memory.limit_in_bytes"))
+		caps.MemoryLimit = err == nil
+
+		_, err = os.Stat(path.Join(mnt, "memory.memsw.limit_in_bytes"))
+		caps.SwapLimit = err == nil
+	}
+
+	return caps
+}
+
+// Returns the major:minor numbers of the block device at p
+func blockDeviceNumbers(p string) (string, error) {
+	fi, err := os.Stat(p)
+
+	if err != nil {
+		return "", err
+	}
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+
+	if !ok || fi.Mode()&os.ModeDevice == 0 || fi.Mode()&os.ModeCharDevice != 0 {
+		return "", fmt.Errorf("%s is not a block device", p)
+	}
+
+	rdev := uint64(st.Rdev)
+	major := (rdev>>8)&0xfff | (rdev>>32)&^0xfff
+	minor := rdev&0xff | (rdev>>12)&^0xff
+
+	return fmt.Sprintf("%d:%d", major, minor), nil
+}
+
+// ParseThrottleDevice parses a rate limit given as /dev/path:rate, where
+// rate is a size per second like 10m.
+func ParseThrottleDevice(spec string) (ThrottleDevice, error) {
+	n := strings.LastIndex(spec, ":")
+
+	if n < 0 {
+		return ThrottleDevice{}, fmt.Errorf("Invalid device rate %s, use <device>:<rate>", spec)
+	}
+
+	dev, rate := spec[:n], spec[n+1:]
+
+	if !path.IsAbs(dev) {
+		return ThrottleDevice{}, fmt.Errorf("Invalid device %s, it must be an absolute path", dev)
+	}
+
+	bps, err := utils.RAMInBytes(rate)
+
+	if err != nil || bps == 0 {
+		return ThrottleDevice{}, fmt.Errorf("Invalid rate %s for %s", rate, dev)
+	}
+
+	return ThrottleDevice{Path: dev, Rate: bps}, nil
+}
+
+// Check the resource limits of config before a container is made with it
+func checkLimits(config *Config) error {
+	if config.Memory != 0 && config.Memory < 524288 {
+		return fmt.Errorf("Memory limit must be given in bytes (minimum 524288 bytes)")
+	}
+
+	if config.MemorySwap > 0 {
+		if config.Memory == 0 {
+			return fmt.Errorf("A swap limit needs a memory limit as well")
+		}
+
+		if config.MemorySwap < config.Memory {
+			return fmt.Errorf("The swap limit includes memory, it can't be less than the memory limit")
+		}
+	}
+
+	if config.Cpus < 0 || config.Cpus > float64(runtime.NumCPU()) {
+		return fmt.Errorf("Invalid number of CPUs %g, there are %d available", config.Cpus, runtime.NumCPU())
+	}
+
+	if config.CpusetCpus != "" && !cpusetRegexp.MatchString(config.CpusetCpus) {
+		return fmt.Errorf("Invalid cpuset %s, use a list like 0-2,4", config.CpusetCpus)
+	}
+
+	if config.BlkioWeight != 0 && (config.BlkioWeight < 10 || config.BlkioWeight > 1000) {
+		return fmt.Errorf("Invalid blkio weight %d, it must be between 10 and 1000", config.BlkioWeight)
+	}
+
+	for _, dev := range config.DeviceReadBps {
+		if _, err := blockDeviceNumbers(dev.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The total of memory and swap the container may use, 0 for no limit. By
+// default swap may be as large as the memory limit.
+func memorySwap(config *Config) int64 {
+	switch {
+	case config.Memory == 0 || config.MemorySwap < 0:
+		return 0
+	case config.MemorySwap > 0:
+		return config.MemorySwap
+	}
+
+	return config.Memory * 2
+}
+
+// The cgroup settings for the container's resource limits, as v2 or v1 keys
+func cgroupSettings(config *Config, v2 bool) ([]cgroupSetting, error) {
+	var out []cgroupSetting
+
+	prefix := "lxc.cgroup."
+
+	if v2 {
+		prefix = "lxc.cgroup2."
+	}
+
+	add := func(key string, value interface{}) {
+		out = append(out, cgroupSetting{prefix + key, fmt.Sprint(value)})
+	}
+
+	if config.Memory > 0 {
+		swap := memorySwap(config)
+
+		if v2 {
+			add("memory.max", config.Memory)
+
+			// v2 limits the swap alone rather than memory plus swap
+			if swap > 0 {
+				add("memory.swap.max", swap-config.Memory)
+			}
+		} else {
+			add("memory.limit_in_bytes", config.Memory)
+			add("memory.soft_limit_in_bytes", config.Memory)
+
+			if swap > 0 {
+				add("memory.memsw.limit_in_bytes", swap)
+			}
+		}
+	}
+
+	if config.CpuShares > 0 {
+		if v2 {
+			// The conversion runc uses, mapping shares 2..262144 onto 1..10000
+			shares := config.CpuShares
+
+			if shares < 2 {
+				shares = 2
+			}
+
+			add("cpu.weight", 1+((shares-2)*9999)/262142)
+		} else {
+			add("cpu.shares", config.CpuShares)
+		}
+	}
+
+	if config.Cpus > 0 {
+		quota := int64(config.Cpus * cpuPeriod)
+
+		if v2 {
+			add("cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod))
+		} else {
+			add("cpu.cfs_period_us", cpuPeriod)
+			add("cpu.cfs_quota_us", quota)
+		}
+	}
+
+	if config.CpusetCpus != "" {
+		add("cpuset.cpus", config.CpusetCpus)
+	}
+
+	if config.PidsLimit > 0 {
+		add("pids.max", config.PidsLimit)
+	}
+
+	if config.BlkioWeight > 0 {
+		if v2 {
+			add("io.weight", config.BlkioWeight)
+		} else {
+			add("blkio.weight", config.BlkioWeight)
+		}
+	}
+
+	for _, dev := range config.DeviceReadBps {
+		nums, err := blockDeviceNumbers(dev.Path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if v2 {
+			add("io.max", fmt.Sprintf("%s rbps=%d", nums, dev.Rate))
+		} else {
+			add("blkio.throttle.read_bps_device", fmt.Sprintf("%s %d", nums, dev.Rate))
+		}
+	}
+
+	return out, nil
+}
+
+// Returns the pid of the container's init, the first process inside it.
+// lxc-start, whose pid is recorded, runs it as its child.
+func (container *Container) initPid() (int, error) {
+	monitor := container.runningPid()
+
+	if !processAlive(monitor) {
+		return 0, fmt.Errorf("Container %s is not running", utils.TruncateID(container.ID))
+	}
+
+	ents, err := ioutil.ReadDir("/proc")
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ent := range ents {
+		pid, err := strconv.Atoi(ent.Name())
+
+		if err != nil {
+			continue
+		}
+
+		if ppid, err := parentPid(pid); err == nil && ppid == monitor {
+			return pid, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unable to find the init process of container %s", utils.TruncateID(container.ID))
+}
+
+// Returns the parent of pid from /proc/<pid>/stat
+func parentPid(pid int) (int, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+
+	if err != nil {
+		return 0, err
+	}
+
+	// The command name is in parens and may contain spaces, so parse from
+	// after the last one: state ppid ...
+	s := string(data)
+	fields := strings.Fields(s[strings.LastIndex(s, ")")+1:])
+
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("Malformed stat for %d", pid)
+	}
+
+	return strconv.Atoi(fields[1])
+}
+
+// The cgroup directories a process is in
+type cgroupDirs struct {
+	v2   bool
+	dirs map[string]string // v1 controller to directory, "" for the v2 one
+}
+
+// Looks up the cgroups of pid from /proc/<pid>/cgroup
+func cgroupsOf(pid int) (*cgroupDirs, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+
+	if err != nil {
+		return nil, err
+	}
+
+	cg := &cgroupDirs{v2: CgroupV2(), dirs: make(map[string]string)}
+
+	// Lines look like 4:cpu,cpuacct:/lxc/<id>, or 0::/<path> for v2
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+
+		if len(parts) != 3 {
+			continue
+		}
+
+		if parts[1] == "" {
+			if cg.v2 {
+				mnt, err := utils.FindCgroup2Mountpoint()
+
+				if err != nil {
+					return nil, err
+				}
+
+				cg.dirs[""] = path.Join(mnt, parts[2])
+			}
+
+			continue
+		}
+
+		for _, ctrl := range strings.Split(parts[1], ",") {
+			if mnt, err := utils.FindCgroupMountpoint(ctrl); err == nil {
+				cg.dirs[ctrl] = path.Join(mnt, parts[2])
+			}
+		}
+	}
+
+	return cg, nil
+}
+
+// Returns the directory for controller, which is the same for all of them
+// under v2
+func (cg *cgroupDirs) path(controller string) (string, error) {
+	if cg.v2 {
+		controller = ""
+	}
+
+	dir, ok := cg.dirs[controller]
+
+	if !ok {
+		return "", fmt.Errorf("No %s cgroup found", controller)
+	}
+
+	return dir, nil
+}
+
+func (cg *cgroupDirs) readFile(controller, name string) (string, error) {
+	dir, err := cg.path(controller)
+
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(path.Join(dir, name))
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (cg *cgroupDirs) readUint(controller, name string) (uint64, error) {
+	s, err := cg.readFile(controller, name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// v2 files hold max when there is no limit
+	if s == "max" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// Parses the "key value" lines of files like memory.stat
+func (cg *cgroupDirs) readKeyValues(controller, name string) (map[string]uint64, error) {
+	s, err := cg.readFile(controller, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make(map[string]uint64)
+
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) != 2 {
+			continue
+		}
+
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			vals[fields[0]] = v
+		}
+	}
+
+	return vals, nil
+}
+
+// Returns the cgroups of the running container
+func (container *Container) cgroups() (*cgroupDirs, error) {
+	pid, err := container.initPid()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cgroupsOf(pid)
+}
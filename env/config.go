@@ -13,9 +13,14 @@ type ServiceSpec struct {
 type Config struct {
 	Hostname        string
 	User            string
-	Memory          int64 // Memory limit (in bytes)
-	MemorySwap      int64 // Total memory usage (memory + swap); set `-1' to disable swap
-	CpuShares       int64 // CPU shares (relative weight vs. other containers)
+	Memory          int64            // Memory limit (in bytes)
+	MemorySwap      int64            // Total memory usage (memory + swap); 0 for twice Memory, `-1' for unlimited swap
+	CpuShares       int64            // CPU shares (relative weight vs. other containers)
+	Cpus            float64          `json:",omitempty"` // Number of CPUs worth of time the container may use
+	CpusetCpus      string           `json:",omitempty"` // CPUs the container may run on, eg. 0-2,4
+	PidsLimit       int64            `json:",omitempty"` // Maximum number of processes
+	BlkioWeight     uint16           `json:",omitempty"` // Block IO weight (10 to 1000)
+	DeviceReadBps   []ThrottleDevice `json:",omitempty"` // Read rate limits for block devices
 	AttachStdin     bool
 	AttachStdout    bool
 	AttachStderr    bool
@@ -32,6 +37,7 @@ type Config struct {
 	VolumesFrom     string
 	Entrypoint      []string
 	NetworkDisabled bool
+	Labels          map[string]string `json:",omitempty"`
 }
 
 // Compare two Config struct. Do not compare the "Image" nor "Hostname" fields
@@ -47,6 +53,10 @@ func CompareConfig(a, b *Config) bool {
 		a.Memory != b.Memory ||
 		a.MemorySwap != b.MemorySwap ||
 		a.CpuShares != b.CpuShares ||
+		a.Cpus != b.Cpus ||
+		a.CpusetCpus != b.CpusetCpus ||
+		a.PidsLimit != b.PidsLimit ||
+		a.BlkioWeight != b.BlkioWeight ||
 		a.OpenStdin != b.OpenStdin ||
 		a.Tty != b.Tty ||
 		a.VolumesFrom != b.VolumesFrom {
@@ -57,10 +67,18 @@ func CompareConfig(a, b *Config) bool {
 		len(a.Env) != len(b.Env) ||
 		len(a.PortSpecs) != len(b.PortSpecs) ||
 		len(a.Entrypoint) != len(b.Entrypoint) ||
-		len(a.Volumes) != len(b.Volumes) {
+		len(a.Volumes) != len(b.Volumes) ||
+		len(a.Labels) != len(b.Labels) ||
+		len(a.DeviceReadBps) != len(b.DeviceReadBps) {
 		return false
 	}
 
+	for i := range a.DeviceReadBps {
+		if a.DeviceReadBps[i] != b.DeviceReadBps[i] {
+			return false
+		}
+	}
+
 	for i := 0; i < len(a.Cmd); i++ {
 		if a.Cmd[i] != b.Cmd[i] {
 			return false
@@ -91,6 +109,11 @@ func CompareConfig(a, b *Config) bool {
 			return false
 		}
 	}
+	for key, val := range a.Labels {
+		if bval, exists := b.Labels[key]; !exists || bval != val {
+			return false
+		}
+	}
 	return true
 }
 
@@ -107,6 +130,21 @@ func MergeConfig(userConf, imageConf *Config) {
 	if userConf.CpuShares == 0 {
 		userConf.CpuShares = imageConf.CpuShares
 	}
+	if userConf.Cpus == 0 {
+		userConf.Cpus = imageConf.Cpus
+	}
+	if userConf.CpusetCpus == "" {
+		userConf.CpusetCpus = imageConf.CpusetCpus
+	}
+	if userConf.PidsLimit == 0 {
+		userConf.PidsLimit = imageConf.PidsLimit
+	}
+	if userConf.BlkioWeight == 0 {
+		userConf.BlkioWeight = imageConf.BlkioWeight
+	}
+	if len(userConf.DeviceReadBps) == 0 {
+		userConf.DeviceReadBps = imageConf.DeviceReadBps
+	}
 	// TODO(kev): It's conceivable we'll want to take on-run service defs.
 	//            This'll need to be updated to merge the other direction if so.
 	if userConf.ServiceSpecs == nil || len(userConf.ServiceSpecs) == 0 {
@@ -177,4 +215,12 @@ func MergeConfig(userConf, imageConf *Config) {
 			userConf.Volumes[k] = v
 		}
 	}
+	if userConf.Labels == nil && len(imageConf.Labels) > 0 {
+		userConf.Labels = make(map[string]string)
+	}
+	for k, v := range imageConf.Labels {
+		if _, exists := userConf.Labels[k]; !exists {
+			userConf.Labels[k] = v
+		}
+	}
 }
@@ -0,0 +1,36 @@
+package env
+
+import "testing"
+
+func TestMergeImageConfigLimits(t *testing.T) {
+	tests := []struct {
+		user  Config
+		image Config
+		ok    bool
+	}{
+		{Config{Memory: 1 << 30}, Config{}, true},
+		{Config{Memory: 1 << 30, MemorySwap: 1 << 29}, Config{}, false},
+
+		// Swap inherited from the image is checked against the memory given
+		{Config{Memory: 4 << 30}, Config{Memory: 1 << 30, MemorySwap: 2 << 30}, false},
+		{Config{Memory: 1 << 30}, Config{Memory: 1 << 29, MemorySwap: 2 << 30}, true},
+
+		// As is memory inherited from the image against the swap given
+		{Config{MemorySwap: 2 << 30}, Config{Memory: 1 << 30}, true},
+		{Config{MemorySwap: 2 << 30}, Config{}, false},
+
+		{Config{}, Config{BlkioWeight: 5}, false},
+		{Config{}, Config{CpusetCpus: "0-"}, false},
+	}
+
+	for i, test := range tests {
+		user, image := test.user, test.image
+		err := mergeImageConfig(&user, &Image{Config: &image})
+
+		if test.ok && err != nil {
+			t.Errorf("%d: unexpected error: %s", i, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%d: merged config %+v passed the limit checks", i, user)
+		}
+	}
+}
@@ -41,7 +41,8 @@ func SelfPath() string {
 type Container struct {
 	root string
 
-	ID string
+	ID   string
+	Name string
 
 	Created time.Time
 
@@ -69,8 +70,10 @@ type Container struct {
 	waitLock chan struct{}
 	Volumes  map[string]string
 	// Store rw/ro in a separate structure to preserve reverse-compatibility on-disk.
-	// Easier than migrating older container configs :)
+	// Containers written before it existed are given one by migrateVolumesRW.
 	VolumesRW map[string]bool
+	// Mount propagation of the volumes which asked for one
+	VolumesPropagation map[string]string `json:",omitempty"`
 
 	networkManager *NetworkManager
 }
@@ -82,25 +85,42 @@ type HostConfig struct {
 	Quiet           bool
 	EnvDir          string
 	Hook            string
+	ReadonlyRootfs  bool              `json:",omitempty"`
+	Tmpfs           map[string]string `json:",omitempty"`
 }
 
+// BindMap is a parsed volume specification, see ParseBind. Either SrcPath
+// is a host path or Volume names a named volume.
 type BindMap struct {
-	SrcPath string
-	DstPath string
-	Mode    string
+	SrcPath     string
+	DstPath     string
+	Mode        string
+	Volume      string
+	Propagation string
+	Relabel     string
+	NoCopy      bool
 }
 
 type Capabilities struct {
 	MemoryLimit    bool
 	SwapLimit      bool
 	IPv4Forwarding bool
+	CgroupV2       bool
 }
 
-func ContainerCreate(r *TagStore, config *Config) (*Container, error) {
-	if config.Memory != 0 && config.Memory < 524288 {
-		return nil, fmt.Errorf("Memory limit must be given in bytes (minimum 524288 bytes)")
+// Fills in config from the image's and checks the resulting limits, since
+// some of them, like swap against memory, may come from either.
+func mergeImageConfig(config *Config, img *Image) error {
+	if img.Config != nil {
+		MergeConfig(config, img.Config)
 	}
 
+	return checkLimits(config)
+}
+
+// ContainerCreate creates a new container from config. If name is empty a
+// name is generated for it.
+func ContainerCreate(r *TagStore, config *Config, name string) (*Container, error) {
 	var err error
 	var img *Image
 
@@ -112,10 +132,10 @@ func ContainerCreate(r *TagStore, config *Config) (*Container, error) {
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		if img.Config != nil {
-			MergeConfig(config, img.Config)
-		}
+	if err := mergeImageConfig(config, img); err != nil {
+		return nil, err
 	}
 
 	if len(config.Entrypoint) != 0 && config.Cmd == nil {
@@ -127,6 +147,12 @@ func ContainerCreate(r *TagStore, config *Config) (*Container, error) {
 	// Generate id
 	id := utils.GenerateID()
 
+	name, err = reserveName(name, id)
+
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate default hostname
 	// FIXME: the lxc template no longer needs to set a default hostname
 	if config.Hostname == "" {
@@ -146,6 +172,7 @@ func ContainerCreate(r *TagStore, config *Config) (*Container, error) {
 
 	container := &Container{
 		ID:              id,
+		Name:            name,
 		Created:         time.Now(),
 		Path:            entrypoint,
 		Args:            args, //FIXME: de-duplicate from config
@@ -162,6 +189,7 @@ func ContainerCreate(r *TagStore, config *Config) (*Container, error) {
 	// Step 1: create the container directory.
 	// This doubles as a barrier to avoid race conditions.
 	if err := os.Mkdir(container.root, 0700); err != nil {
+		releaseName(name, id)
 		return nil, err
 	}
 
@@ -205,8 +233,33 @@ func (container *Container) PathTo(fileName string) string {
 	return path.Join(container.root, fileName)
 }
 
-func (container *Container) Commit(comment, author string, config *Config, squash bool, fast bool) (*Image, error) {
+// Commit makes a new image from the container's changes. With pause a
+// running container is frozen while its rw layer is copied, so the image
+// gets a consistent snapshot.
+func (container *Container) Commit(comment, author string, config *Config, squash bool, fast bool, pause bool) (*Image, error) {
+	if !pause || !container.IsRunning() || container.State.Paused {
+		return container.commit(comment, author, config, squash, fast)
+	}
+
+	if err := container.Pause(); err != nil {
+		return nil, err
+	}
+
+	img, err := container.commit(comment, author, config, squash, fast)
+
+	// Left frozen the service would hang with nobody told why
+	if uerr := container.Unpause(); uerr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("%s, and unable to unpause the container: %s", err, uerr)
+		}
+
+		return nil, fmt.Errorf("Committed image %s but unable to unpause the container: %s", utils.TruncateID(img.ID), uerr)
+	}
+
+	return img, err
+}
 
+func (container *Container) commit(comment, author string, config *Config, squash bool, fast bool) (*Image, error) {
 	if config == nil {
 		config = container.Config
 	} else {
@@ -226,7 +279,7 @@ func (container *Container) Commit(comment, author string, config *Config, squas
 
 	logv("Creating image %s", utils.TruncateID(img.ID))
 
-	root := path.Join(DIR, "graph", "_armktmp-"+img.ID)
+	root := path.Join(DIR, "graph", commitTmpPrefix+img.ID)
 
 	os.MkdirAll(root, 0755)
 
@@ -248,6 +301,8 @@ func (container *Container) Commit(comment, author string, config *Config, squas
 		}
 	}
 
+	img.Size = LayerSizeAt(root)
+
 	jsonData, err := json.Marshal(img)
 
 	if err != nil {
@@ -319,16 +374,113 @@ func LoadContainer(dir, id string) (*Container, error) {
 		return nil, err
 	}
 
+	// The state on disk isn't updated if the run process dies, so
+	// trust the process table instead.
+	if cont.State.Running && !cont.IsRunning() {
+		cont.State.Running = false
+		cont.State.Paused = false
+	}
+
 	return cont, nil
 }
 
-func (container *Container) generateLXCConfig() error {
+// Command returns the command line the container was started with
+func (container *Container) Command() string {
+	return strings.TrimSpace(container.Path + " " + strings.Join(container.Args, " "))
+}
+
+// LoadContainers loads every container stored under dir, skipping any
+// that can not be read.
+func LoadContainers(dir string) ([]*Container, error) {
+	ents, err := ioutil.ReadDir(path.Join(dir, "containers"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var conts []*Container
+
+	for _, ent := range ents {
+		cont, err := LoadContainer(dir, ent.Name())
+		if err != nil {
+			continue
+		}
+
+		conts = append(conts, cont)
+	}
+
+	return conts, nil
+}
+
+// IsRunning reports whether a run process has advertised the container
+// as running and that process is still alive.
+func (container *Container) IsRunning() bool {
+	return processAlive(container.runningPid())
+}
+
+// What LxcTemplate is rendered with
+type lxcConfigData struct {
+	*Container
+	HostConfig     *HostConfig
+	TmpfsMounts    map[string]string
+	HostsPath      string // bind mounted on HostsMount when the rootfs is read-only
+	HostsMount     string
+	CgroupPrefix   string
+	CgroupSettings []cgroupSetting
+}
+
+func (container *Container) generateLXCConfig(hostConfig *HostConfig) error {
+	v2 := CgroupV2()
+
+	settings, err := cgroupSettings(container.Config, v2)
+
+	if err != nil {
+		return err
+	}
+
+	tmpfs, err := container.tmpfsMounts(hostConfig)
+
+	if err != nil {
+		return err
+	}
+
+	data := &lxcConfigData{
+		Container:      container,
+		HostConfig:     hostConfig,
+		TmpfsMounts:    tmpfs,
+		CgroupPrefix:   "lxc.cgroup",
+		CgroupSettings: settings,
+	}
+
+	if v2 {
+		data.CgroupPrefix = "lxc.cgroup2"
+	}
+
+	if hostConfig.ReadonlyRootfs {
+		if data.HostsMount, err = container.resolveMountpoint("/etc/hosts"); err != nil {
+			return err
+		}
+
+		// Only an image without /etc/hosts needs a file made to mount on
+		target := path.Join(container.RootfsPath(), data.HostsMount)
+
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			os.MkdirAll(path.Dir(target), 0755)
+
+			if err := ioutil.WriteFile(target, nil, 0644); err != nil {
+				return err
+			}
+		}
+
+		data.HostsPath = container.PathTo("hosts")
+	}
+
 	fo, err := os.Create(container.lxcConfigPath())
 	if err != nil {
 		return err
 	}
 	defer fo.Close()
-	if err := LxcTemplateCompiled.Execute(fo, container); err != nil {
+	if err := LxcTemplateCompiled.Execute(fo, data); err != nil {
 		return err
 	}
 	return nil
@@ -480,14 +632,82 @@ func (container *Container) cleanup() {
 	}
 }
 
+// Copy the volumes of the container named by Config.VolumesFrom, given as
+// <container>[:ro|:rw]. With ro every volume is mounted read-only, otherwise
+// each keeps the mode it has in the source container.
+func (container *Container) applyVolumesFrom() error {
+	spec := container.Config.VolumesFrom
+
+	if spec == "" {
+		return nil
+	}
+
+	ref, mode := spec, "rw"
+
+	if n := strings.LastIndex(spec, ":"); n >= 0 {
+		ref, mode = spec[:n], strings.ToLower(spec[n+1:])
+
+		if mode != "ro" && mode != "rw" {
+			return fmt.Errorf("Invalid mode for volumes-from: %s", spec)
+		}
+	}
+
+	from, err := ResolveContainer(ref)
+
+	if err != nil {
+		return fmt.Errorf("Unable to find container %s to take volumes from: %s", ref, err)
+	}
+
+	if from.ID == container.ID {
+		return fmt.Errorf("A container can not take volumes from itself")
+	}
+
+	// Its volumes are only known once it has been started. Carrying on
+	// would leave this container without them for good, since VolumesFrom
+	// is not looked at again once it has volumes of its own.
+	if len(from.Volumes) == 0 {
+		return fmt.Errorf("Container %s has no volumes to take, it may never have been started", ref)
+	}
+
+	for volPath, src := range from.Volumes {
+		dst, err := container.resolveMountpoint(volPath)
+
+		if err != nil {
+			return err
+		}
+
+		container.Volumes[dst] = src
+		container.VolumesRW[dst] = from.VolumesRW[volPath] && mode == "rw"
+
+		if prop, ok := from.VolumesPropagation[volPath]; ok {
+			container.VolumesPropagation[dst] = prop
+		}
+
+		if err := os.MkdirAll(path.Join(container.RootfsPath(), dst), 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (container *Container) Start(hostConfig *HostConfig) error {
 	defer container.cleanup()
 
+	c, _ := container.ReadHostConfig()
+
 	if len(hostConfig.Binds) == 0 {
-		c, _ := container.ReadHostConfig()
 		hostConfig.Binds = c.Binds
 	}
 
+	if len(hostConfig.Tmpfs) == 0 {
+		hostConfig.Tmpfs = c.Tmpfs
+	}
+
+	if c.ReadonlyRootfs {
+		hostConfig.ReadonlyRootfs = true
+	}
+
 	if container.State.Running {
 		return fmt.Errorf("The container %s is already running.", container.ID)
 	}
@@ -501,91 +721,117 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 	}
 
 	// Create the requested bind mounts
-	binds := make(map[string]BindMap)
-	// Define illegal container destinations
-	illegalDsts := []string{"/", "."}
-
-	for _, bind := range hostConfig.Binds {
-		// FIXME: factorize bind parsing in parseBind
-		var src, dst, mode string
-		arr := strings.Split(bind, ":")
-		if len(arr) == 2 {
-			src = arr[0]
-			dst = arr[1]
-			mode = "rw"
-		} else if len(arr) == 3 {
-			src = arr[0]
-			dst = arr[1]
-			mode = arr[2]
-		} else {
-			return fmt.Errorf("Invalid bind specification: %s", bind)
-		}
+	binds := make(map[string]*BindMap)
 
-		// Bail if trying to mount to an illegal destination
-		for _, illegal := range illegalDsts {
-			if dst == illegal {
-				return fmt.Errorf("Illegal bind destination: %s", dst)
-			}
+	for _, spec := range hostConfig.Binds {
+		bind, err := ParseBind(spec)
+
+		if err != nil {
+			return err
 		}
 
-		if dst[0:1] == "@" {
+		if bind.Volume != "" {
 			continue
 		}
 
-		bindMap := BindMap{
-			SrcPath: src,
-			DstPath: dst,
-			Mode:    mode,
+		if _, err := os.Stat(bind.SrcPath); err != nil {
+			return fmt.Errorf("Bind source %s does not exist", bind.SrcPath)
 		}
-		binds[path.Clean(dst)] = bindMap
+
+		if _, exists := binds[bind.DstPath]; exists {
+			return fmt.Errorf("Duplicate mount point: %s", bind.DstPath)
+		}
+
+		binds[bind.DstPath] = bind
 	}
 
-	// FIXME: evaluate volumes-from before individual volumes, so that the latter can override the former.
 	// Create the requested volumes volumes
 	if container.Volumes == nil || len(container.Volumes) == 0 {
 		container.Volumes = make(map[string]string)
 		container.VolumesRW = make(map[string]bool)
+		container.VolumesPropagation = make(map[string]string)
 
-		for volPath := range container.Config.Volumes {
-			volPath = path.Clean(volPath)
+		// Volumes from another container go first so that the container's
+		// own volumes can override them
+		if err := container.applyVolumesFrom(); err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool)
+
+		for spec := range container.Config.Volumes {
 			// If an external bind is defined for this volume, use that as a source
-			if bindMap, exists := binds[volPath]; exists {
-				container.Volumes[volPath] = bindMap.SrcPath
-				if strings.ToLower(bindMap.Mode) == "rw" {
-					container.VolumesRW[volPath] = true
+			vol, exists := binds[path.Clean(spec)]
+
+			// Otherwise use the named volume in $ROOT/volumes/
+			if !exists {
+				var err error
+
+				if vol, err = ParseBind(spec); err != nil || vol.Volume == "" {
+					return fmt.Errorf("Invalid volume configuration: %s", spec)
 				}
-				// Otherwise create an directory in $ROOT/volumes/ and use that
-			} else {
-				n := strings.LastIndex(volPath, ":@")
-				if n < 0 {
-					return fmt.Errorf("Invalid volume configuration: %s", volPath)
-				} else {
-					volName := path.Join(DIR, "volumes", volPath[n+2:])
-					volPath = volPath[0:n]
+			}
+
+			dst, err := container.resolveMountpoint(vol.DstPath)
 
-					os.MkdirAll(volName, 0755)
+			if err != nil {
+				return err
+			}
+
+			if seen[dst] {
+				return fmt.Errorf("Duplicate mount point: %s", dst)
+			}
 
-					container.Volumes[volPath] = volName
-					container.VolumesRW[volPath] = true
+			seen[dst] = true
+
+			src := vol.SrcPath
+
+			if vol.Volume != "" {
+				if src, err = PrepareVolume(vol.Volume); err != nil {
+					return err
 				}
 			}
 
+			container.Volumes[dst] = src
+			container.VolumesRW[dst] = vol.Mode == "rw"
+
+			if vol.Propagation != "" {
+				container.VolumesPropagation[dst] = vol.Propagation
+			}
+
 			// Create the mountpoint
-			if err := os.MkdirAll(path.Join(container.RootfsPath(), volPath), 0755); err != nil {
-				return nil
+			if err := os.MkdirAll(path.Join(container.RootfsPath(), dst), 0755); err != nil {
+				return err
 			}
 		}
 	}
 
-	if err := container.generateLXCConfig(); err != nil {
+	// Named volumes backed by a mount may be gone since the last run, or
+	// were never mounted if they came from another container
+	for name := range VolumeUsers([]*Container{container}) {
+		if _, err := PrepareVolume(name); err != nil {
+			return err
+		}
+	}
+
+	if err := container.generateLXCConfig(hostConfig); err != nil {
 		return err
 	}
 
 	// Update /etc/hosts in the container to have an etc/hosts entry
 	// for itself.
+	// A read-only container has it bind mounted instead, so nothing is
+	// written to the rw layer.
 	hosts := defaultHosts + "\n127.0.0.1\t" + container.Config.Hostname + "\n"
-	os.MkdirAll(path.Join(container.rwPath(), "etc"), 0755)
-	err := ioutil.WriteFile(path.Join(container.rwPath(), "etc/hosts"), []byte(hosts), 0644)
+	hostsPath := path.Join(container.rwPath(), "etc/hosts")
+
+	if hostConfig.ReadonlyRootfs {
+		hostsPath = container.PathTo("hosts")
+	} else {
+		os.MkdirAll(path.Join(container.rwPath(), "etc"), 0755)
+	}
+
+	err := ioutil.WriteFile(hostsPath, []byte(hosts), 0644)
 
 	if err != nil {
 		fmt.Printf("error writing hosts file: %s\n", err)
@@ -673,7 +919,13 @@ func (container *Container) Start(hostConfig *HostConfig) error {
 	return nil
 }
 
+// Signal sends sig to lxc-start. A frozen container can't act on it, not
+// even on SIGKILL, so a paused one is thawed first.
 func (container *Container) Signal(sig os.Signal) {
+	if err := container.thaw(); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: Unable to unpause %s: %s\n", utils.TruncateID(container.ID), err)
+	}
+
 	container.cmd.Process.Signal(sig)
 }
 
@@ -737,31 +989,84 @@ func (c *Container) Wait(cfg *HostConfig) {
 	pid := fmt.Sprintf("%d\n", c.cmd.Process.Pid)
 	ioutil.WriteFile(path.Join(c.root, "running"), []byte(pid), 0644)
 
-	if cfg.Hook != "" {
-		cmd := exec.Command(cfg.Hook, "started", c.ID)
-		cmd.Stdout = os.Stdout
-		cmd.Stdout = os.Stderr
+	// Finding the container's cgroups waits on lxc-start, which mustn't
+	// hold up the hook. Without the watch nothing is known about OOM
+	// kills, but the container still runs fine.
+	watch := make(chan *memoryWatch, 1)
+
+	go func() {
+		mem, err := c.watchMemory()
+
+		// One that has already exited has nothing to watch
+		if err != nil && c.IsRunning() {
+			fmt.Fprintf(os.Stderr, "WARNING: Unable to watch the memory use of %s: %s\n", utils.TruncateID(c.ID), err)
+		}
+
+		watch <- mem
+	}()
+
+	c.runHook(cfg, "started")
+
+	exitCode := 0
 
-		cmd.Run()
+	if err := c.cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				exitCode = status.ExitStatus()
+			}
+		}
+	}
+
+	// Once lxc-start has exited the watch gives up waiting, if it still is
+	if mem := <-watch; mem != nil {
+		mem.Stop()
+		c.State.OOMKilled = mem.OOMKilled
+		c.State.MemoryPeak = mem.Peak
 	}
 
-	c.cmd.Wait()
 	c.Unmount()
 
-	c.network.Release()
+	if c.network != nil {
+		c.network.Release()
+	}
 
-	c.setStopped(0)
+	c.setStopped(exitCode)
+
+	if c.State.OOMKilled {
+		c.runHook(cfg, "oom")
+	}
 
 	if cfg.Save {
 		if !cfg.Quiet {
 			fmt.Printf("== Saved: %s\n", c.ID)
 		}
 		os.RemoveAll(path.Join(c.root, "running"))
+
+		// The container may have been renamed while it ran
+		if name, ok := nameOf(c.ID); ok {
+			c.Name = name
+		}
+
+		c.ToDisk()
 	} else {
-		os.RemoveAll(path.Join(DIR, "containers", c.ID))
+		c.Remove()
 	}
 }
 
+// Runs the hook of cfg, if any, telling it what has happened to the
+// container: started or oom
+func (c *Container) runHook(cfg *HostConfig, event string) {
+	if cfg.Hook == "" {
+		return
+	}
+
+	cmd := exec.Command(cfg.Hook, event, c.ID)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	cmd.Run()
+}
+
 func (c *Container) setStopped(exitCode int) {
 	pid := strconv.Itoa(c.State.Pid)
 	// Nuke fs-level presence information
@@ -770,8 +1075,16 @@ func (c *Container) setStopped(exitCode int) {
 	c.State.setStopped(exitCode)
 }
 
-func (c *Container) Remove() {
-	os.RemoveAll(c.root)
+func (c *Container) Remove() error {
+	if mounted, _ := c.Mounted(); mounted {
+		c.Unmount()
+	}
+
+	if err := os.RemoveAll(c.root); err != nil {
+		return err
+	}
+
+	return releaseName(c.Name, c.ID)
 }
 
 // Inject the io.Reader at the given path. Note: do not close the reader
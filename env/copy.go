@@ -0,0 +1,316 @@
+package env
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"github.com/vektra/container/utils"
+)
+
+// View returns a MergedView of the container's filesystem, its rw branch
+// on top of the layers of its image. It reflects the current contents of
+// running containers too, since aufs writes all changes into rw.
+func (container *Container) View(ts *TagStore) (*MergedView, error) {
+	v := &MergedView{layers: []layerReader{dirLayer(container.rwPath())}}
+
+	if container.Image == "" {
+		return v, nil
+	}
+
+	img := container.imageO
+
+	if img == nil {
+		var ok bool
+
+		if img, ok = ts.Entries[container.Image]; !ok {
+			return nil, fmt.Errorf("Image %s of the container is missing", utils.TruncateID(container.Image))
+		}
+	}
+
+	iv, err := img.View()
+
+	if err != nil {
+		return nil, err
+	}
+
+	v.layers = append(v.layers, iv.layers...)
+
+	return v, nil
+}
+
+// WriteTar writes src, and everything under it if it is a directory, to w
+// as a tar stream. The top entry is called name. Ownership, modes and
+// modification times are preserved.
+func (v *MergedView) WriteTar(w io.Writer, src, name string) error {
+	src, err := v.resolve(src, true)
+
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	if err := v.tarEntry(tw, src, name); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func (v *MergedView) tarEntry(tw *tar.Writer, src, name string) error {
+	fi, err := v.Lstat(src)
+
+	if err != nil {
+		return err
+	}
+
+	var link string
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if link, err = v.Readlink(src); err != nil {
+			return err
+		}
+	}
+
+	hdr, err := tar.FileInfoHeader(fi, link)
+
+	if err != nil {
+		return err
+	}
+
+	hdr.Name = name
+	hdr.Uname = ""
+	hdr.Gname = ""
+
+	switch sys := fi.Sys().(type) {
+	case *syscall.Stat_t:
+		hdr.Uid = int(sys.Uid)
+		hdr.Gid = int(sys.Gid)
+	case *squashEntry:
+		hdr.Uid = sys.uid
+		hdr.Gid = sys.gid
+	}
+
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	switch {
+	case fi.Mode().IsRegular():
+		f, err := v.Open(src)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, f)
+		f.Close()
+
+		return err
+	case fi.IsDir():
+		ents, err := v.ReadDir(src)
+
+		if err != nil {
+			return err
+		}
+
+		for _, ent := range ents {
+			if err := v.tarEntry(tw, path.Join(src, ent.Name()), path.Join(name, ent.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CopyIn copies the host file or directory src into the container at dst.
+// If dst is an existing directory src is placed inside it, otherwise src is
+// copied to dst itself. Running containers are written through their mounted
+// rootfs, stopped ones straight into their rw branch with any whiteouts
+// covering the copied paths removed.
+func (container *Container) CopyIn(ts *TagStore, src, dst string) error {
+	view, err := container.View(ts)
+
+	if err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(src)
+
+	if err != nil {
+		return err
+	}
+
+	dst = cleanViewPath(dst)
+
+	parent, name := dst, path.Base(abs)
+
+	if fi, err := view.Stat(dst); err != nil || !fi.IsDir() {
+		parent, name = path.Dir(dst), path.Base(dst)
+	}
+
+	if parent, err = view.resolve(parent, true); err != nil {
+		return err
+	}
+
+	if fi, err := view.Stat(parent); err != nil || !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory in the container", parent)
+	}
+
+	root := container.rwPath()
+	mounted, _ := container.Mounted()
+
+	if mounted {
+		root = container.RootfsPath()
+	} else if err := view.mkdirParents(root, parent); err != nil {
+		return err
+	}
+
+	// Unpack into a scratch dir on the same filesystem so the entry can be
+	// given its new name with a rename.
+	tmp, err := ioutil.TempDir(path.Join(root, parent), ".cp-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmp)
+
+	archive, err := utils.TarFilter(path.Dir(abs), utils.Uncompressed, []string{path.Base(abs)})
+
+	if err != nil {
+		return err
+	}
+
+	if err := utils.Untar(archive, tmp); err != nil {
+		return err
+	}
+
+	staged := path.Join(tmp, path.Base(abs))
+	target := path.Join(root, parent, name)
+
+	sfi, err := os.Lstat(staged)
+
+	if err != nil {
+		return err
+	}
+
+	tfi, err := os.Lstat(target)
+
+	if err == nil && sfi.IsDir() && tfi.IsDir() {
+		// Merge into the directory that is already there
+		if err := utils.TarUntar(staged, nil, target); err != nil {
+			return err
+		}
+	} else {
+		if err == nil {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Rename(staged, target); err != nil {
+			return err
+		}
+	}
+
+	if !mounted {
+		return clearWhiteouts(path.Join(root, parent), name)
+	}
+
+	return nil
+}
+
+// Make the directories leading to dir exist in the rw branch at root,
+// copying their mode and ownership from the view.
+func (v *MergedView) mkdirParents(root, dir string) error {
+	if dir == "/" {
+		return nil
+	}
+
+	if err := v.mkdirParents(root, path.Dir(dir)); err != nil {
+		return err
+	}
+
+	target := path.Join(root, dir)
+
+	if _, err := os.Lstat(target); err == nil {
+		return nil
+	}
+
+	fi, err := v.Lstat(dir)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Mkdir(target, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	switch sys := fi.Sys().(type) {
+	case *syscall.Stat_t:
+		os.Lchown(target, int(sys.Uid), int(sys.Gid))
+	case *squashEntry:
+		os.Lchown(target, sys.uid, sys.gid)
+	}
+
+	return nil
+}
+
+// Remove the whiteout for name in dir and for everything below it. A
+// directory which was whited out is made opaque instead, so the contents it
+// had in lower layers stay hidden.
+func clearWhiteouts(dir, name string) error {
+	wh := path.Join(dir, whiteoutPrefix+name)
+	target := path.Join(dir, name)
+
+	if _, err := os.Lstat(wh); err == nil {
+		if err := os.Remove(wh); err != nil {
+			return err
+		}
+
+		if fi, err := os.Lstat(target); err == nil && fi.IsDir() {
+			f, err := os.Create(path.Join(target, whiteoutOpaqueDir))
+			if err != nil {
+				return err
+			}
+			f.Close()
+		}
+	}
+
+	fi, err := os.Lstat(target)
+
+	if err != nil || !fi.IsDir() {
+		return nil
+	}
+
+	ents, err := ioutil.ReadDir(target)
+
+	if err != nil {
+		return err
+	}
+
+	for _, ent := range ents {
+		if isWhiteout(ent.Name()) {
+			continue
+		}
+
+		if err := clearWhiteouts(target, ent.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
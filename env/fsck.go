@@ -0,0 +1,296 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// Problem is an inconsistency found in the DIR tree by Fsck
+type Problem struct {
+	Path string
+	Desc string
+
+	repair func() error
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Desc)
+}
+
+// Repairable reports whether Repair knows how to fix the problem
+func (p *Problem) Repairable() bool {
+	return p.repair != nil
+}
+
+// Repair fixes the problem, usually by moving the broken entry out of the
+// way into DIR/quarantine.
+func (p *Problem) Repair() error {
+	if p.repair == nil {
+		return fmt.Errorf("No repair available")
+	}
+
+	return p.repair()
+}
+
+type fsck struct {
+	problems   []*Problem
+	quarantine string
+	images     map[string]*Image
+}
+
+func (f *fsck) add(pth, desc string, repair func() error) {
+	f.problems = append(f.problems, &Problem{Path: pth, Desc: desc, repair: repair})
+}
+
+// Returns a repair func which moves pth into the quarantine directory,
+// preserving its path relative to DIR.
+func (f *fsck) quarantineFn(pth string) func() error {
+	return func() error {
+		rel := strings.TrimPrefix(pth, DIR+"/")
+		dest := path.Join(f.quarantine, rel)
+
+		// Squashfs layers and container roots may still be mounted
+		for _, sub := range []string{"layer", "rootfs"} {
+			if mounted, _ := Mounted(path.Join(pth, sub)); mounted {
+				utils.RunUnchecked("umount", path.Join(pth, sub))
+			}
+		}
+
+		if err := os.MkdirAll(path.Dir(dest), 0700); err != nil {
+			return err
+		}
+
+		return os.Rename(pth, dest)
+	}
+}
+
+// Fsck validates the graph, tag store and containers under DIR and returns
+// every problem found. Nothing is changed until Repair is called on the
+// returned problems.
+func Fsck() ([]*Problem, error) {
+	f := &fsck{
+		quarantine: path.Join(DIR, "quarantine", time.Now().Format("20060102-150405")),
+		images:     make(map[string]*Image),
+	}
+
+	if err := f.checkGraph(); err != nil {
+		return nil, err
+	}
+
+	f.checkParents()
+	f.checkTags()
+
+	if err := f.checkContainers(); err != nil {
+		return nil, err
+	}
+
+	f.checkNames()
+	f.checkJSON(path.Join(DIR, "ips"), &ipConfig{})
+
+	return f.problems, nil
+}
+
+func (f *fsck) checkJSON(pth string, v interface{}) {
+	data, err := ioutil.ReadFile(pth)
+
+	if err != nil {
+		return
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		f.add(pth, fmt.Sprintf("corrupt json: %s", err), f.quarantineFn(pth))
+	}
+}
+
+func (f *fsck) checkGraph() error {
+	graph := path.Join(DIR, "graph")
+
+	dirs, err := ioutil.ReadDir(graph)
+
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		id := d.Name()
+		root := path.Join(graph, id)
+
+		if id == "_init" {
+			continue
+		}
+
+		if strings.HasPrefix(id, importTmpPrefix) || strings.HasPrefix(id, commitTmpPrefix) {
+			// A recent one is likely still in use
+			if time.Since(d.ModTime()) < DefaultPruneAge {
+				continue
+			}
+
+			f.add(root, "leftover temporary directory", func() error {
+				return os.RemoveAll(root)
+			})
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path.Join(root, "json"))
+
+		if err != nil {
+			f.add(root, "image has no json", f.quarantineFn(root))
+			continue
+		}
+
+		img := &Image{}
+
+		if err := json.Unmarshal(data, img); err != nil {
+			f.add(path.Join(root, "json"), fmt.Sprintf("corrupt image json: %s", err), f.quarantineFn(root))
+			continue
+		}
+
+		img.ID = id
+
+		_, fsErr := os.Stat(path.Join(root, "layer.fs"))
+		lst, _ := ioutil.ReadDir(path.Join(root, "layer"))
+
+		if fsErr != nil && len(lst) == 0 {
+			f.add(root, "image layer is empty and there is no layer.fs", f.quarantineFn(root))
+			continue
+		}
+
+		f.images[id] = img
+	}
+
+	return nil
+}
+
+func (f *fsck) checkParents() {
+	for id, img := range f.images {
+		if img.Parent == "" {
+			continue
+		}
+
+		if _, ok := f.images[img.Parent]; !ok {
+			root := path.Join(DIR, "graph", id)
+			f.add(root, fmt.Sprintf("parent image %s is missing", utils.TruncateID(img.Parent)),
+				f.quarantineFn(root))
+		}
+	}
+}
+
+func (f *fsck) checkTags() {
+	repoPath := path.Join(DIR, "repositories")
+
+	ts, err := readRepoFile(repoPath, repoPath)
+
+	if err != nil {
+		f.add(repoPath, fmt.Sprintf("unable to read tags: %s", err), func() error {
+			if _, err := readRepoFile(repoPath, repoPath+".bak"); err == nil {
+				data, err := ioutil.ReadFile(repoPath + ".bak")
+				if err != nil {
+					return err
+				}
+				return utils.AtomicWriteFile(repoPath, data, 0644, false)
+			}
+
+			return ioutil.WriteFile(repoPath, []byte("{}"), 0644)
+		})
+		return
+	}
+
+	for repo, tags := range ts.Repositories {
+		for tag, id := range tags {
+			if _, ok := f.images[id]; ok {
+				continue
+			}
+
+			repo, tag := repo, tag
+
+			f.add(repoPath, fmt.Sprintf("%s:%s points at missing image %s", repo, tag, utils.TruncateID(id)),
+				func() error {
+					return ts.Update(func(ts *TagStore) error {
+						ts.RemoveTag(repo, tag)
+						return nil
+					})
+				})
+		}
+	}
+}
+
+func (f *fsck) checkContainers() error {
+	dirs, err := ioutil.ReadDir(path.Join(DIR, "containers"))
+
+	if err != nil {
+		return err
+	}
+
+	for _, d := range dirs {
+		root := path.Join(DIR, "containers", d.Name())
+
+		data, err := ioutil.ReadFile(path.Join(root, "config.json"))
+
+		if err != nil {
+			f.add(root, "container has no config.json", f.quarantineFn(root))
+			continue
+		}
+
+		cont := &Container{root: root}
+
+		if err := json.Unmarshal(data, cont); err != nil {
+			f.add(path.Join(root, "config.json"), fmt.Sprintf("corrupt container config: %s", err),
+				f.quarantineFn(root))
+			continue
+		}
+
+		if cont.Image != "" {
+			if _, ok := f.images[cont.Image]; !ok {
+				if cont.IsRunning() {
+					f.add(root, fmt.Sprintf("running container uses missing image %s",
+						utils.TruncateID(cont.Image)), nil)
+				} else {
+					f.add(root, fmt.Sprintf("container uses missing image %s",
+						utils.TruncateID(cont.Image)), f.quarantineFn(root))
+				}
+				continue
+			}
+		}
+
+		hcPath := cont.hostConfigPath()
+
+		if data, err := ioutil.ReadFile(hcPath); err == nil {
+			if err := json.Unmarshal(data, &HostConfig{}); err != nil {
+				f.add(hcPath, fmt.Sprintf("corrupt host config: %s", err), func() error {
+					return os.Remove(hcPath)
+				})
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *fsck) checkNames() {
+	names, err := readNames()
+
+	if err != nil {
+		f.add(namesPath(), err.Error(), f.quarantineFn(namesPath()))
+		return
+	}
+
+	for name, id := range names {
+		if _, err := os.Stat(path.Join(DIR, "containers", id)); err == nil {
+			continue
+		}
+
+		name, id := name, id
+
+		f.add(namesPath(), fmt.Sprintf("name %s points at missing container %s", name, utils.TruncateID(id)),
+			func() error {
+				return releaseName(name, id)
+			})
+	}
+}
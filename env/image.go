@@ -24,9 +24,18 @@ type Image struct {
 	Architecture    string    `json:"architecture,omitempty"`
 	Size            int64
 	Ids             []string
+	History         []ImageHistory `json:"history,omitempty"`
 	parentImage     *Image
 }
 
+// Records an image that was collapsed into another by Squash
+type ImageHistory struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Author  string    `json:"author,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+}
+
 func (image *Image) WithPrimaryId(fn func(string)) {
 	if len(image.Ids) > 0 {
 		fn(image.Ids[0])
@@ -60,27 +69,14 @@ func (image *Image) layers() ([]string, error) {
 	var layers []string
 
 	if image.ID != "" {
-		cur := image
-
-		for cur != nil {
-			lp := path.Join(DIR, "graph", cur.ID, "layer")
-
-			os.MkdirAll(lp, 0755)
+		for _, cur := range image.chain() {
+			lp, err := cur.mountLayer()
 
-			lst, _ := ioutil.ReadDir(lp)
-
-			if len(lst) == 0 {
-				lpfs := path.Join(DIR, "graph", cur.ID, "layer.fs")
-
-				if _, err := os.Stat(lpfs); err == nil {
-					utils.Run("mount", lpfs, lp)
-				} else {
-					return nil, fmt.Errorf("No layer.fs file to mount")
-				}
+			if err != nil {
+				return nil, err
 			}
 
 			layers = append(layers, lp)
-			cur = cur.parentImage
 		}
 	}
 
@@ -88,6 +84,61 @@ func (image *Image) layers() ([]string, error) {
 	return layers, nil
 }
 
+// Returns the image followed by each of its parents, top most first.
+func (image *Image) chain() []*Image {
+	var imgs []*Image
+
+	for cur := image; cur != nil; cur = cur.parentImage {
+		imgs = append(imgs, cur)
+	}
+
+	return imgs
+}
+
+// HasAncestor reports whether id is the image itself or one of its parents.
+func (image *Image) HasAncestor(id string) bool {
+	for _, cur := range image.chain() {
+		if cur.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParentIDs returns the ids of the image's parents, closest first.
+func (image *Image) ParentIDs() []string {
+	var ids []string
+
+	for _, cur := range image.chain()[1:] {
+		ids = append(ids, cur.ID)
+	}
+
+	return ids
+}
+
+// Make sure the layer directory for the image is populated, mounting
+// the squashfs version of it if that is how it was stored.
+func (image *Image) mountLayer() (string, error) {
+	lp := path.Join(DIR, "graph", image.ID, "layer")
+
+	os.MkdirAll(lp, 0755)
+
+	lst, _ := ioutil.ReadDir(lp)
+
+	if len(lst) == 0 {
+		lpfs := path.Join(DIR, "graph", image.ID, "layer.fs")
+
+		if _, err := os.Stat(lpfs); err == nil {
+			utils.Run("mount", lpfs, lp)
+		} else {
+			return "", fmt.Errorf("No layer.fs file to mount")
+		}
+	}
+
+	return lp, nil
+}
+
 func (image *Image) Remove() error {
 	lpfs := path.Join(DIR, "graph", image.ID, "layer.fs")
 
@@ -0,0 +1,79 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// ImportRootfs creates a new base image from archive, a tarball of a root
+// filesystem such as the output of debootstrap. The archive may be
+// compressed.
+func ImportRootfs(archive io.Reader, comment, author string, config *Config, squashfs bool) (*Image, error) {
+	img := &Image{
+		ID:           utils.GenerateID(),
+		Comment:      comment,
+		Created:      time.Now(),
+		Author:       author,
+		Config:       config,
+		Architecture: "x86_64",
+	}
+
+	logv("Importing rootfs as %s", utils.TruncateID(img.ID))
+
+	root := path.Join(DIR, "graph", importTmpPrefix+img.ID)
+	layerPath := path.Join(root, "layer")
+
+	flat := layerPath
+	if squashfs {
+		flat = path.Join(root, "flat")
+	}
+
+	if err := os.MkdirAll(flat, 0755); err != nil {
+		return nil, err
+	}
+
+	if err := utils.Untar(archive, flat); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	if squashfs {
+		logv("Generating squashfs...")
+
+		if out, err := utils.RunUnchecked("mksquashfs", flat, path.Join(root, "layer.fs"), "-comp", "xz"); err != nil {
+			os.RemoveAll(root)
+			return nil, fmt.Errorf("mksquashfs failed: %s: %s", err, out)
+		}
+
+		os.RemoveAll(flat)
+		os.MkdirAll(layerPath, 0755)
+	}
+
+	img.Size = LayerSizeAt(root)
+
+	jsonData, err := json.Marshal(img)
+
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(path.Join(root, "json"), jsonData, 0644); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	if err := os.Rename(root, path.Join(DIR, "graph", img.ID)); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	return img, nil
+}
@@ -0,0 +1,72 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseLabels turns key=value strings into a label map. A label given
+// without a value is set to the empty string.
+func ParseLabels(specs []string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		key := strings.TrimSpace(parts[0])
+
+		if key == "" {
+			return nil, fmt.Errorf("Invalid label '%s', expected key=value", spec)
+		}
+
+		if len(parts) == 2 {
+			labels[key] = parts[1]
+		} else {
+			labels[key] = ""
+		}
+	}
+
+	return labels, nil
+}
+
+// MatchLabel reports whether labels satisfy sel, which is either a bare
+// key that must be present or key=value.
+func MatchLabel(labels map[string]string, sel string) bool {
+	parts := strings.SplitN(sel, "=", 2)
+
+	val, ok := labels[parts[0]]
+
+	if !ok {
+		return false
+	}
+
+	return len(parts) == 1 || val == parts[1]
+}
+
+// MatchLabels reports whether labels satisfy every selector in sels
+func MatchLabels(labels map[string]string, sels []string) bool {
+	for _, sel := range sels {
+		if !MatchLabel(labels, sel) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Labels returns the labels the container was created with
+func (container *Container) Labels() map[string]string {
+	if container.Config == nil {
+		return nil
+	}
+
+	return container.Config.Labels
+}
+
+// Labels returns the labels recorded in the image's config
+func (image *Image) Labels() map[string]string {
+	if image.Config == nil {
+		return nil
+	}
+
+	return image.Config.Labels
+}
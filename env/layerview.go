@@ -0,0 +1,627 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A single read-only layer of a MergedView
+type layerReader interface {
+	lstat(p string) (os.FileInfo, error)
+	readDir(p string) ([]os.FileInfo, error)
+	readlink(p string) (string, error)
+	open(p string) (io.ReadCloser, error)
+}
+
+// MergedView gives read access to the union of a stack of layers, the way
+// aufs would present them, without mounting anything. Whiteouts in upper
+// layers hide the entries below them.
+type MergedView struct {
+	layers []layerReader
+}
+
+// View returns a MergedView of the image and all of its parents
+func (image *Image) View() (*MergedView, error) {
+	v := &MergedView{}
+
+	for _, cur := range image.chain() {
+		l, err := cur.layerReader()
+
+		if err != nil {
+			return nil, err
+		}
+
+		v.layers = append(v.layers, l)
+	}
+
+	return v, nil
+}
+
+// Read the layer from its directory if it has been unpacked or mounted,
+// otherwise straight out of layer.fs.
+func (image *Image) layerReader() (layerReader, error) {
+	lp := path.Join(DIR, "graph", image.ID, "layer")
+	lpfs := path.Join(DIR, "graph", image.ID, "layer.fs")
+
+	if lst, _ := ioutil.ReadDir(lp); len(lst) > 0 {
+		return dirLayer(lp), nil
+	}
+
+	if _, err := os.Stat(lpfs); err == nil {
+		return loadSquashLayer(lpfs)
+	}
+
+	return dirLayer(lp), nil
+}
+
+// Returns the cleaned, absolute form of p
+func cleanViewPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// Reports whether layer l hides p from the layers below it, either with a
+// whiteout, an opaque directory or by replacing a parent with a non-directory.
+func masks(l layerReader, p string) bool {
+	if p == "/" {
+		return false
+	}
+
+	for cur := p; cur != "/"; cur = path.Dir(cur) {
+		if _, err := l.lstat(path.Join(path.Dir(cur), whiteoutPrefix+path.Base(cur))); err == nil {
+			return true
+		}
+
+		if fi, err := l.lstat(cur); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		if _, err := l.lstat(path.Join(dir, whiteoutOpaqueDir)); err == nil {
+			return true
+		}
+
+		if dir == "/" {
+			break
+		}
+	}
+
+	return false
+}
+
+// Find the top most layer containing p, without following symlinks
+func (v *MergedView) find(p string) (layerReader, os.FileInfo, error) {
+	for _, l := range v.layers {
+		if fi, err := l.lstat(p); err == nil {
+			return l, fi, nil
+		}
+
+		if masks(l, p) {
+			break
+		}
+	}
+
+	return nil, nil, &os.PathError{Op: "lstat", Path: p, Err: os.ErrNotExist}
+}
+
+// Resolve symlinks in p within the view. The last component is only
+// followed if followLast is set.
+func (v *MergedView) resolve(p string, followLast bool) (string, error) {
+	rest := strings.Split(strings.Trim(cleanViewPath(p), "/"), "/")
+	cur := "/"
+	hops := 0
+
+	for len(rest) > 0 {
+		if rest[0] == "" {
+			rest = rest[1:]
+			continue
+		}
+
+		next := path.Join(cur, rest[0])
+		rest = rest[1:]
+
+		l, fi, err := v.find(next)
+
+		if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 || (len(rest) == 0 && !followLast) {
+			cur = next
+			continue
+		}
+
+		if hops++; hops > 40 {
+			return "", fmt.Errorf("Too many levels of symbolic links: %s", p)
+		}
+
+		target, err := l.readlink(next)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !path.IsAbs(target) {
+			target = path.Join(cur, target)
+		}
+
+		rest = append(strings.Split(strings.Trim(cleanViewPath(target), "/"), "/"), rest...)
+		cur = "/"
+	}
+
+	return cur, nil
+}
+
+// Lstat returns information about p in the view. Symlinks in the
+// directories leading up to p are followed, p itself is not.
+func (v *MergedView) Lstat(p string) (os.FileInfo, error) {
+	rp, err := v.resolve(p, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rp == "/" {
+		return v.rootInfo()
+	}
+
+	_, fi, err := v.find(rp)
+
+	return fi, err
+}
+
+// Stat is like Lstat but also follows p if it is a symlink
+func (v *MergedView) Stat(p string) (os.FileInfo, error) {
+	rp, err := v.resolve(p, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rp == "/" {
+		return v.rootInfo()
+	}
+
+	_, fi, err := v.find(rp)
+
+	return fi, err
+}
+
+func (v *MergedView) rootInfo() (os.FileInfo, error) {
+	for _, l := range v.layers {
+		if fi, err := l.lstat("/"); err == nil {
+			return fi, nil
+		}
+	}
+
+	return nil, &os.PathError{Op: "lstat", Path: "/", Err: os.ErrNotExist}
+}
+
+// Readlink returns the target of the symlink at p
+func (v *MergedView) Readlink(p string) (string, error) {
+	rp, err := v.resolve(p, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	l, _, err := v.find(rp)
+
+	if err != nil {
+		return "", err
+	}
+
+	return l.readlink(rp)
+}
+
+// ReadDir lists the directory p, merging the entries of every layer and
+// dropping those that have been whited out. Entries are sorted by name.
+func (v *MergedView) ReadDir(p string) ([]os.FileInfo, error) {
+	rp, err := v.resolve(p, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rp != "/" {
+		_, fi, err := v.find(rp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("%s: not a directory", p)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var out []os.FileInfo
+
+	for _, l := range v.layers {
+		ents, _ := l.readDir(rp)
+
+		var whiteouts []string
+		opaque := false
+
+		for _, fi := range ents {
+			name := fi.Name()
+
+			switch {
+			case name == whiteoutOpaqueDir:
+				opaque = true
+			case strings.HasPrefix(name, whiteoutMetaPrefix):
+			case isWhiteout(name):
+				whiteouts = append(whiteouts, name[len(whiteoutPrefix):])
+			case !seen[name]:
+				seen[name] = true
+				out = append(out, fi)
+			}
+		}
+
+		// A whiteout only hides entries in the layers below it
+		for _, name := range whiteouts {
+			seen[name] = true
+		}
+
+		if opaque || masks(l, rp) {
+			break
+		}
+	}
+
+	sort.Sort(byName(out))
+
+	return out, nil
+}
+
+// Open returns the contents of the file at p, following symlinks
+func (v *MergedView) Open(p string) (io.ReadCloser, error) {
+	rp, err := v.resolve(p, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	l, fi, err := v.find(rp)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: not a regular file", p)
+	}
+
+	return l.open(rp)
+}
+
+type byName []os.FileInfo
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name() < b[j].Name() }
+
+// A layer stored as a plain directory
+type dirLayer string
+
+// Returns where p is on the host. The host would follow a symlink in any
+// parent of p, possibly out of the layer, so p is only in the layer when
+// every parent is a real directory. Symlinks are left to MergedView, and
+// unless allowLink is set p itself may not be one, since opening it would
+// follow it.
+func (d dirLayer) hostPath(p string, allowLink bool) (string, error) {
+	p = cleanViewPath(p)
+
+	if !allowLink {
+		if fi, err := os.Lstat(path.Join(string(d), p)); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s is a symbolic link", p)
+		}
+	}
+
+	for dir := path.Dir(p); dir != "/"; dir = path.Dir(dir) {
+		fi, err := os.Lstat(path.Join(string(d), dir))
+
+		if err != nil {
+			return "", err
+		}
+
+		if !fi.IsDir() {
+			return "", &os.PathError{Op: "lstat", Path: p, Err: os.ErrNotExist}
+		}
+	}
+
+	return path.Join(string(d), p), nil
+}
+
+func (d dirLayer) lstat(p string) (os.FileInfo, error) {
+	hp, err := d.hostPath(p, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Lstat(hp)
+}
+
+func (d dirLayer) readDir(p string) ([]os.FileInfo, error) {
+	hp, err := d.hostPath(p, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadDir(hp)
+}
+
+func (d dirLayer) readlink(p string) (string, error) {
+	hp, err := d.hostPath(p, true)
+
+	if err != nil {
+		return "", err
+	}
+
+	return os.Readlink(hp)
+}
+
+func (d dirLayer) open(p string) (io.ReadCloser, error) {
+	hp, err := d.hostPath(p, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(hp)
+}
+
+// A layer read out of a squashfs image with unsquashfs, which doesn't
+// need the image to be mounted.
+type squashLayer struct {
+	file    string
+	entries map[string]*squashEntry
+	dirs    map[string][]string
+}
+
+type squashEntry struct {
+	name    string
+	mode    os.FileMode
+	size    int64
+	modTime time.Time
+	uid     int
+	gid     int
+	target  string
+}
+
+func (e *squashEntry) Name() string       { return e.name }
+func (e *squashEntry) Size() int64        { return e.size }
+func (e *squashEntry) Mode() os.FileMode  { return e.mode }
+func (e *squashEntry) ModTime() time.Time { return e.modTime }
+func (e *squashEntry) IsDir() bool        { return e.mode.IsDir() }
+func (e *squashEntry) Sys() interface{}   { return e }
+
+func loadSquashLayer(file string) (*squashLayer, error) {
+	out, err := exec.Command("unsquashfs", "-lln", file).Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list %s: %s", file, err)
+	}
+
+	l := &squashLayer{
+		file:    file,
+		entries: make(map[string]*squashEntry),
+		dirs:    make(map[string][]string),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+
+	for scanner.Scan() {
+		p, ent, ok := parseSquashListing(scanner.Text())
+
+		if !ok {
+			continue
+		}
+
+		l.entries[p] = ent
+
+		if p != "/" {
+			l.dirs[path.Dir(p)] = append(l.dirs[path.Dir(p)], p)
+		}
+	}
+
+	return l, scanner.Err()
+}
+
+// Parse a line of unsquashfs -lln output, which looks like
+//
+//	-rw-r--r-- 0/0   1234 2014-01-02 15:04 squashfs-root/etc/passwd
+//	lrwxrwxrwx 0/0      7 2014-01-02 15:04 squashfs-root/bin -> usr/bin
+//	crw-rw-rw- 0/0  1,  3 2014-01-02 15:04 squashfs-root/dev/null
+func parseSquashListing(line string) (string, *squashEntry, bool) {
+	const root = "squashfs-root"
+
+	fields := strings.Fields(line)
+
+	if len(fields) < 6 || len(fields[0]) != 10 {
+		return "", nil, false
+	}
+
+	mode, ok := parseModeString(fields[0])
+
+	if !ok {
+		return "", nil, false
+	}
+
+	idx := strings.Index(line, " "+root)
+
+	if idx < 0 {
+		return "", nil, false
+	}
+
+	name := line[idx+1+len(root):]
+	ent := &squashEntry{mode: mode}
+
+	if owner := strings.SplitN(fields[1], "/", 2); len(owner) == 2 {
+		ent.uid, _ = strconv.Atoi(owner[0])
+		ent.gid, _ = strconv.Atoi(owner[1])
+	}
+
+	if mode&os.ModeSymlink != 0 {
+		if n := strings.Index(name, " -> "); n >= 0 {
+			ent.target = name[n+4:]
+			name = name[:n]
+		}
+	}
+
+	// Devices show major, minor instead of a size
+	if mode&os.ModeDevice == 0 {
+		ent.size, _ = strconv.ParseInt(fields[2], 10, 64)
+	}
+
+	meta := strings.Fields(line[:idx])
+
+	if len(meta) >= 2 {
+		stamp := meta[len(meta)-2] + " " + meta[len(meta)-1]
+		ent.modTime, _ = time.ParseInLocation("2006-01-02 15:04", stamp, time.Local)
+	}
+
+	p := cleanViewPath(name)
+	ent.name = path.Base(p)
+
+	return p, ent, true
+}
+
+// Convert an ls style mode string like drwxr-xr-x into an os.FileMode
+func parseModeString(s string) (os.FileMode, bool) {
+	var mode os.FileMode
+
+	switch s[0] {
+	case '-':
+	case 'd':
+		mode |= os.ModeDir
+	case 'l':
+		mode |= os.ModeSymlink
+	case 'c':
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 'b':
+		mode |= os.ModeDevice
+	case 'p':
+		mode |= os.ModeNamedPipe
+	case 's':
+		mode |= os.ModeSocket
+	default:
+		return 0, false
+	}
+
+	for i, c := range s[1:] {
+		bit := os.FileMode(1) << uint(8-i)
+
+		switch c {
+		case 'r', 'w', 'x':
+			mode |= bit
+		case 's':
+			mode |= bit
+			if i == 2 {
+				mode |= os.ModeSetuid
+			} else {
+				mode |= os.ModeSetgid
+			}
+		case 'S':
+			if i == 2 {
+				mode |= os.ModeSetuid
+			} else {
+				mode |= os.ModeSetgid
+			}
+		case 't':
+			mode |= bit | os.ModeSticky
+		case 'T':
+			mode |= os.ModeSticky
+		case '-':
+		default:
+			return 0, false
+		}
+	}
+
+	return mode, true
+}
+
+func (l *squashLayer) lstat(p string) (os.FileInfo, error) {
+	if ent, ok := l.entries[p]; ok {
+		return ent, nil
+	}
+
+	return nil, &os.PathError{Op: "lstat", Path: p, Err: os.ErrNotExist}
+}
+
+func (l *squashLayer) readDir(p string) ([]os.FileInfo, error) {
+	ent, ok := l.entries[p]
+
+	if !ok || !ent.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: p, Err: os.ErrNotExist}
+	}
+
+	var out []os.FileInfo
+
+	for _, child := range l.dirs[p] {
+		out = append(out, l.entries[child])
+	}
+
+	return out, nil
+}
+
+func (l *squashLayer) readlink(p string) (string, error) {
+	ent, ok := l.entries[p]
+
+	if !ok || ent.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: p, Err: os.ErrInvalid}
+	}
+
+	return ent.target, nil
+}
+
+// Extract just p into a temp dir. The dir is removed when the returned
+// reader is closed.
+func (l *squashLayer) open(p string) (io.ReadCloser, error) {
+	tmp, err := ioutil.TempDir("", "vk-unsquash")
+
+	if err != nil {
+		return nil, err
+	}
+
+	dest := path.Join(tmp, "root")
+
+	out, err := exec.Command("unsquashfs", "-n", "-f", "-d", dest, l.file, strings.TrimPrefix(p, "/")).CombinedOutput()
+
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, fmt.Errorf("Unable to extract %s from %s: %s: %s", p, l.file, err, out)
+	}
+
+	f, err := os.Open(path.Join(dest, p))
+
+	if err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	return &tempFile{f, tmp}, nil
+}
+
+type tempFile struct {
+	*os.File
+	dir string
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.RemoveAll(t.dir)
+	return err
+}
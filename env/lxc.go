@@ -29,6 +29,9 @@ lxc.network.ipv4 = {{.NetworkSettings.IPAddress}}/{{.NetworkSettings.IPPrefixLen
 # root filesystem
 {{$ROOTFS := .RootfsPath}}
 lxc.rootfs = {{$ROOTFS}}
+{{if .HostConfig.ReadonlyRootfs}}
+lxc.rootfs.options = ro
+{{end}}
 
 # use a dedicated pts for the container (and limit the number of pseudo terminal
 # available)
@@ -40,29 +43,30 @@ lxc.console = none
 # no controlling tty at all
 lxc.tty = 1
 
+{{$CG := .CgroupPrefix}}
 # no implicit access to devices
-lxc.cgroup.devices.deny = a
+{{$CG}}.devices.deny = a
 
 # /dev/null and zero
-lxc.cgroup.devices.allow = c 1:3 rwm
-lxc.cgroup.devices.allow = c 1:5 rwm
+{{$CG}}.devices.allow = c 1:3 rwm
+{{$CG}}.devices.allow = c 1:5 rwm
 
 # consoles
-lxc.cgroup.devices.allow = c 5:1 rwm
-lxc.cgroup.devices.allow = c 5:0 rwm
-lxc.cgroup.devices.allow = c 4:0 rwm
-lxc.cgroup.devices.allow = c 4:1 rwm
+{{$CG}}.devices.allow = c 5:1 rwm
+{{$CG}}.devices.allow = c 5:0 rwm
+{{$CG}}.devices.allow = c 4:0 rwm
+{{$CG}}.devices.allow = c 4:1 rwm
 
 # /dev/urandom,/dev/random
-lxc.cgroup.devices.allow = c 1:9 rwm
-lxc.cgroup.devices.allow = c 1:8 rwm
+{{$CG}}.devices.allow = c 1:9 rwm
+{{$CG}}.devices.allow = c 1:8 rwm
 
 # /dev/pts/* - pts namespaces are "coming soon"
-lxc.cgroup.devices.allow = c 136:* rwm
-lxc.cgroup.devices.allow = c 5:2 rwm
+{{$CG}}.devices.allow = c 136:* rwm
+{{$CG}}.devices.allow = c 5:2 rwm
 
 # tuntap
-lxc.cgroup.devices.allow = c 10:200 rwm
+{{$CG}}.devices.allow = c 10:200 rwm
 
 # fuse
 #lxc.cgroup.devices.allow = c 10:229 rwm
@@ -79,9 +83,16 @@ lxc.mount.entry = proc {{$ROOTFS}}/proc proc nosuid,nodev,noexec 0 0
 #           if your userspace allows it. eg. see http://bit.ly/T9CkqJ
 lxc.mount.entry = sysfs {{$ROOTFS}}/sys sysfs nosuid,nodev,noexec 0 0
 lxc.mount.entry = devpts {{$ROOTFS}}/dev/pts devpts newinstance,ptmxmode=0666,nosuid,noexec 0 0
-#lxc.mount.entry = varrun {{$ROOTFS}}/var/run tmpfs mode=755,size=4096k,nosuid,nodev,noexec 0 0
-#lxc.mount.entry = varlock {{$ROOTFS}}/var/lock tmpfs size=1024k,nosuid,nodev,noexec 0 0
-#lxc.mount.entry = shm {{$ROOTFS}}/dev/shm tmpfs size=65536k,nosuid,nodev,noexec 0 0
+
+# tmpfs mounts, /var/run, /var/lock and /dev/shm get one when the rootfs is
+# read-only. One inside another, eg. /run/lock in /run, needs its mountpoint
+# made in the outer tmpfs.
+{{range $dst, $opts := .TmpfsMounts}}
+lxc.mount.entry = tmpfs {{$ROOTFS}}{{$dst}} tmpfs {{$opts}},create=dir 0 0
+{{end}}
+{{if .HostsPath}}
+lxc.mount.entry = {{.HostsPath}} {{$ROOTFS}}{{.HostsMount}} none bind,ro 0 0
+{{end}}
 
 # Inject docker-init
 lxc.mount.entry = {{.SysInitPath}} {{$ROOTFS}}/.dockerinit none bind,ro 0 0
@@ -90,8 +101,9 @@ lxc.mount.entry = {{.SysInitPath}} {{$ROOTFS}}/.dockerinit none bind,ro 0 0
 lxc.mount.entry = {{.ResolvConfPath}} {{$ROOTFS}}/etc/resolv.conf none bind,ro 0 0
 {{if .Volumes}}
 {{ $rw := .VolumesRW }}
+{{ $prop := .VolumesPropagation }}
 {{range $virtualPath, $realPath := .Volumes}}
-lxc.mount.entry = {{$realPath}} {{$ROOTFS}}/{{$virtualPath}} none bind,{{ if index $rw $virtualPath }}rw{{else}}ro{{end}} 0 0
+lxc.mount.entry = {{$realPath}} {{$ROOTFS}}/{{$virtualPath}} none bind,{{ if index $rw $virtualPath }}rw{{else}}ro{{end}}{{with index $prop $virtualPath}},{{.}}{{end}} 0 0
 {{end}}
 {{end}}
 
@@ -102,36 +114,17 @@ lxc.mount.entry = {{$realPath}} {{$ROOTFS}}/{{$virtualPath}} none bind,{{ if ind
 lxc.cap.drop = audit_control audit_write mac_admin mac_override mknod setfcap setpcap sys_admin sys_boot sys_module sys_nice sys_pacct sys_rawio sys_resource sys_time sys_tty_config
 
 # limits
-{{if .Config.Memory}}
-lxc.cgroup.memory.limit_in_bytes = {{.Config.Memory}}
-lxc.cgroup.memory.soft_limit_in_bytes = {{.Config.Memory}}
-{{with $memSwap := getMemorySwap .Config}}
-lxc.cgroup.memory.memsw.limit_in_bytes = {{$memSwap}}
-{{end}}
-{{end}}
-{{if .Config.CpuShares}}
-lxc.cgroup.cpu.shares = {{.Config.CpuShares}}
+{{range .CgroupSettings}}
+{{.Key}} = {{.Value}}
 {{end}}
 `
 
 var LxcTemplateCompiled *template.Template
 
-func getMemorySwap(config *Config) int64 {
-	// By default, MemorySwap is set to twice the size of RAM.
-	// If you want to omit MemorySwap, set it to `-1'.
-	if config.MemorySwap < 0 {
-		return 0
-	}
-	return config.Memory * 2
-}
-
 func init() {
 	var err error
-	funcMap := template.FuncMap{
-		"getMemorySwap": getMemorySwap,
-	}
 
-	LxcTemplateCompiled, err = template.New("lxc").Funcs(funcMap).Parse(LxcTemplate)
+	LxcTemplateCompiled, err = template.New("lxc").Parse(LxcTemplate)
 
 	if err != nil {
 		panic(err)
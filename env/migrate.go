@@ -0,0 +1,248 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// SchemaVersion is the version of the on-disk layout under DIR that this
+// binary reads and writes. Bump it and add an entry to migrations whenever
+// the format of config.json, hostconfig.json, image json, repositories or
+// ips changes.
+const SchemaVersion = 1
+
+type migration struct {
+	desc string
+	run  func() error
+}
+
+// migrations[i] upgrades a DIR at version i to version i+1
+var migrations = []migration{
+	{"Record rw/ro state for volumes of older containers", migrateVolumesRW},
+}
+
+func versionPath() string {
+	return path.Join(DIR, "version")
+}
+
+// Returns the schema version of DIR. A tree without a version file is
+// treated as version 0, unless it is empty in which case it is new.
+func readSchemaVersion() (int, error) {
+	data, err := ioutil.ReadFile(versionPath())
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+
+		if emptyDir(path.Join(DIR, "graph")) && emptyDir(path.Join(DIR, "containers")) {
+			return SchemaVersion, nil
+		}
+
+		return 0, nil
+	}
+
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	if err != nil {
+		return 0, fmt.Errorf("Invalid schema version in %s: %s", versionPath(), err)
+	}
+
+	return v, nil
+}
+
+func emptyDir(dir string) bool {
+	ents, _ := ioutil.ReadDir(dir)
+	return len(ents) == 0
+}
+
+func writeSchemaVersion(v int) error {
+	return utils.AtomicWriteFile(versionPath(), []byte(strconv.Itoa(v)+"\n"), 0644, false)
+}
+
+// Migrate upgrades the on-disk layout to SchemaVersion, backing up the
+// metadata files first. It refuses to touch a tree written by a newer
+// version of the binary.
+func Migrate() error {
+	// Fast path which doesn't need write access to DIR
+	if cur, err := readSchemaVersion(); err == nil && cur == SchemaVersion {
+		if _, err := os.Stat(versionPath()); err == nil {
+			return nil
+		}
+	}
+
+	lock, err := os.OpenFile(versionPath()+".lock", os.O_WRONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	cur, err := readSchemaVersion()
+
+	if err != nil {
+		return err
+	}
+
+	if cur > SchemaVersion {
+		return fmt.Errorf("%s uses schema version %d but this binary only supports up to %d, please upgrade",
+			DIR, cur, SchemaVersion)
+	}
+
+	if cur == SchemaVersion {
+		if _, err := os.Stat(versionPath()); err != nil {
+			return writeSchemaVersion(cur)
+		}
+		return nil
+	}
+
+	backup := path.Join(DIR, "backup", fmt.Sprintf("schema-%d-%s", cur, time.Now().Format("20060102-150405")))
+
+	fmt.Fprintf(os.Stderr, "Upgrading %s from schema %d to %d (backup in %s)\n", DIR, cur, SchemaVersion, backup)
+
+	if err := backupMetadata(backup); err != nil {
+		return fmt.Errorf("Unable to back up metadata: %s", err)
+	}
+
+	for v := cur; v < SchemaVersion; v++ {
+		m := migrations[v]
+
+		logv("Migration %d: %s", v+1, m.desc)
+
+		if err := m.run(); err != nil {
+			return fmt.Errorf("Migration to schema %d failed: %s (backup in %s)", v+1, err, backup)
+		}
+
+		if err := writeSchemaVersion(v + 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copy every json metadata file under DIR into dest, keeping the relative
+// layout. Layers and volumes are not copied, migrations never touch them.
+func backupMetadata(dest string) error {
+	var files []string
+
+	for _, name := range []string{"repositories", "ips", "names"} {
+		files = append(files, path.Join(DIR, name))
+	}
+
+	for _, pattern := range []string{"graph/*/json", "containers/*/config.json", "containers/*/hostconfig.json"} {
+		matches, err := filepath.Glob(path.Join(DIR, pattern))
+
+		if err != nil {
+			return err
+		}
+
+		files = append(files, matches...)
+	}
+
+	for _, src := range files {
+		rel := strings.TrimPrefix(src, DIR+"/")
+
+		if err := copyFile(src, path.Join(dest, rel)); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	if err := os.MkdirAll(path.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// Containers created before VolumesRW existed mounted every volume rw.
+func migrateVolumesRW() error {
+	matches, err := filepath.Glob(path.Join(DIR, "containers", "*", "config.json"))
+
+	if err != nil {
+		return err
+	}
+
+	for _, pth := range matches {
+		data, err := ioutil.ReadFile(pth)
+
+		if err != nil {
+			return err
+		}
+
+		var raw map[string]interface{}
+
+		if err := json.Unmarshal(data, &raw); err != nil {
+			// fsck is responsible for corrupt configs
+			continue
+		}
+
+		vols, _ := raw["Volumes"].(map[string]interface{})
+
+		if len(vols) == 0 || raw["VolumesRW"] != nil {
+			continue
+		}
+
+		rw := make(map[string]interface{})
+
+		for dst := range vols {
+			rw[dst] = true
+		}
+
+		raw["VolumesRW"] = rw
+
+		data, err = json.Marshal(raw)
+
+		if err != nil {
+			return err
+		}
+
+		if err := utils.AtomicWriteFile(pth, data, 0666, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
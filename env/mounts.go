@@ -0,0 +1,248 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Options every tmpfs is mounted with, ahead of any given by the user so
+// that those win, eg. exec over noexec.
+const tmpfsBaseOptions = "nosuid,nodev,noexec"
+
+// With a read-only rootfs these get a tmpfs unless the user mounts one
+// there already, since most services expect to be able to write to them.
+var readonlyTmpfs = map[string]string{
+	"/var/run":  "mode=755,size=4096k",
+	"/var/lock": "size=1024k",
+	"/dev/shm":  "size=65536k",
+}
+
+// ParseBind parses a volume specification as given to run -v. It is either
+// a bind mount of a host path, src:dst[:options], or a named volume,
+// dst:@name[:options]. The options are a comma separated list of at most
+// one each of ro or rw, z or Z, a mount propagation mode, and nocopy for
+// named volumes.
+//
+// There is no SELinux support, z and Z are accepted so specs written for
+// docker keep working. Named volumes never get a copy of the image's files
+// either, so nocopy has nothing to turn off.
+func ParseBind(spec string) (*BindMap, error) {
+	arr := strings.Split(spec, ":")
+
+	if len(arr) < 2 || len(arr) > 3 {
+		return nil, fmt.Errorf("Invalid volume specification: %s", spec)
+	}
+
+	bind := &BindMap{Mode: "rw"}
+
+	if strings.HasPrefix(arr[1], "@") {
+		bind.DstPath, bind.Volume = arr[0], arr[1][1:]
+
+		if !validName.MatchString(bind.Volume) {
+			return nil, fmt.Errorf("Invalid volume name in %s", spec)
+		}
+	} else {
+		bind.SrcPath, bind.DstPath = arr[0], arr[1]
+
+		if bind.SrcPath == "" {
+			return nil, fmt.Errorf("Missing bind source in %s", spec)
+		}
+
+		if !path.IsAbs(bind.SrcPath) {
+			return nil, fmt.Errorf("Bind source must be an absolute path: %s", bind.SrcPath)
+		}
+
+		bind.SrcPath = path.Clean(bind.SrcPath)
+	}
+
+	if bind.DstPath == "" {
+		return nil, fmt.Errorf("Missing destination in %s", spec)
+	}
+
+	if !path.IsAbs(bind.DstPath) {
+		return nil, fmt.Errorf("Volume destination must be an absolute path: %s", bind.DstPath)
+	}
+
+	bind.DstPath = path.Clean(bind.DstPath)
+
+	if bind.DstPath == "/" {
+		return nil, fmt.Errorf("Illegal bind destination: %s", bind.DstPath)
+	}
+
+	if len(arr) == 3 {
+		if err := bind.parseOptions(arr[2]); err != nil {
+			return nil, fmt.Errorf("%s in %s", err, spec)
+		}
+	}
+
+	return bind, nil
+}
+
+func (bind *BindMap) parseOptions(opts string) error {
+	mode := ""
+
+	for _, opt := range strings.Split(opts, ",") {
+		switch opt {
+		case "ro", "rw", "RO", "RW":
+			if mode != "" {
+				return fmt.Errorf("Conflicting modes %s and %s", mode, opt)
+			}
+			mode = strings.ToLower(opt)
+			bind.Mode = mode
+		case "z", "Z":
+			if bind.Relabel != "" {
+				return fmt.Errorf("Conflicting relabel options %s and %s", bind.Relabel, opt)
+			}
+			bind.Relabel = opt
+		case "nocopy":
+			if bind.Volume == "" {
+				return fmt.Errorf("nocopy only applies to named volumes")
+			}
+			bind.NoCopy = true
+		case "private", "rprivate", "shared", "rshared", "slave", "rslave":
+			if bind.Propagation != "" {
+				return fmt.Errorf("Conflicting propagation modes %s and %s", bind.Propagation, opt)
+			}
+			bind.Propagation = opt
+		default:
+			return fmt.Errorf("Unknown volume option '%s'", opt)
+		}
+	}
+
+	return nil
+}
+
+// Resolve dst inside the rootfs the way it would be seen from within the
+// container, following any symlinks along it. Mounting onto a path whose
+// symlinks were left for the host to follow could land outside the rootfs,
+// so a symlink climbing above the root is refused.
+func (container *Container) resolveMountpoint(dst string) (string, error) {
+	root := container.RootfsPath()
+
+	todo := strings.Split(dst, "/")
+	var done []string
+	hops := 0
+
+	for len(todo) > 0 {
+		part := todo[0]
+		todo = todo[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(done) == 0 {
+				return "", fmt.Errorf("Mount point %s escapes the container root", dst)
+			}
+			done = done[:len(done)-1]
+			continue
+		}
+
+		cur := path.Join(root, path.Join(done...), part)
+		fi, err := os.Lstat(cur)
+
+		// Missing parts are created as directories later
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			done = append(done, part)
+			continue
+		}
+
+		if hops++; hops > 40 {
+			return "", fmt.Errorf("Too many levels of symbolic links in %s", dst)
+		}
+
+		link, err := os.Readlink(cur)
+
+		if err != nil {
+			return "", err
+		}
+
+		if path.IsAbs(link) {
+			done = nil
+		}
+
+		todo = append(strings.Split(link, "/"), todo...)
+	}
+
+	if len(done) == 0 {
+		return "", fmt.Errorf("Illegal bind destination: %s", dst)
+	}
+
+	return "/" + path.Join(done...), nil
+}
+
+// ParseTmpfs splits a tmpfs mount given as /path[:options] into the
+// destination inside the container and the mount options.
+func ParseTmpfs(spec string) (string, string, error) {
+	dst, opts := spec, ""
+
+	if n := strings.Index(spec, ":"); n >= 0 {
+		dst, opts = spec[:n], spec[n+1:]
+	}
+
+	if !path.IsAbs(dst) {
+		return "", "", fmt.Errorf("Invalid tmpfs destination, it must be absolute: %s", dst)
+	}
+
+	dst = path.Clean(dst)
+
+	if dst == "/" {
+		return "", "", fmt.Errorf("Illegal tmpfs destination: %s", dst)
+	}
+
+	if strings.ContainsAny(opts, " \t\n") {
+		return "", "", fmt.Errorf("Invalid tmpfs options: %s", opts)
+	}
+
+	return dst, opts, nil
+}
+
+// Tmpfs mounts to give the container, destination to mount options. The
+// destinations are resolved like bind ones, so the /var/run -> /run symlink
+// many images ship gets its tmpfs on /run rather than on the host's. Every
+// mountpoint is created too, which can't be done from inside a read-only
+// container.
+func (container *Container) tmpfsMounts(hostConfig *HostConfig) (map[string]string, error) {
+	mounts := make(map[string]string)
+
+	add := func(dst, opts string) error {
+		resolved, err := container.resolveMountpoint(dst)
+
+		if err != nil {
+			return err
+		}
+
+		if opts == "" {
+			mounts[resolved] = tmpfsBaseOptions
+		} else {
+			mounts[resolved] = tmpfsBaseOptions + "," + opts
+		}
+
+		return nil
+	}
+
+	if hostConfig.ReadonlyRootfs {
+		for dst, opts := range readonlyTmpfs {
+			if err := add(dst, opts); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// After the defaults, so the user's replace one on the same path
+	for dst, opts := range hostConfig.Tmpfs {
+		if err := add(dst, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	for dst := range mounts {
+		if err := os.MkdirAll(path.Join(container.RootfsPath(), dst), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return mounts, nil
+}
@@ -0,0 +1,162 @@
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestParseBind(t *testing.T) {
+	tests := []struct {
+		spec string
+		want *BindMap // nil when spec is invalid
+	}{
+		{"/src:/dst", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw"}},
+		{"/src/:/dst/../data/", &BindMap{SrcPath: "/src", DstPath: "/data", Mode: "rw"}},
+		{"/dst:@data", &BindMap{DstPath: "/dst", Volume: "data", Mode: "rw"}},
+		{"/dst:@d", &BindMap{DstPath: "/dst", Volume: "d", Mode: "rw"}},
+
+		{"/src:/dst:ro", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "ro"}},
+		{"/src:/dst:RO", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "ro"}},
+		{"/src:/dst:rw", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw"}},
+		{"/src:/dst:z", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Relabel: "z"}},
+		{"/src:/dst:Z", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Relabel: "Z"}},
+		{"/dst:@data:nocopy", &BindMap{DstPath: "/dst", Volume: "data", Mode: "rw", NoCopy: true}},
+		{"/src:/dst:private", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Propagation: "private"}},
+		{"/src:/dst:rprivate", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Propagation: "rprivate"}},
+		{"/src:/dst:shared", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Propagation: "shared"}},
+		{"/src:/dst:rshared", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Propagation: "rshared"}},
+		{"/src:/dst:slave", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Propagation: "slave"}},
+		{"/src:/dst:rslave", &BindMap{SrcPath: "/src", DstPath: "/dst", Mode: "rw", Propagation: "rslave"}},
+		{"/dst:@data:ro,Z,rshared,nocopy", &BindMap{DstPath: "/dst", Volume: "data", Mode: "ro",
+			Relabel: "Z", Propagation: "rshared", NoCopy: true}},
+
+		// Malformed
+		{"/src", nil},
+		{"/src:/dst:ro:extra", nil},
+		{"src:", nil},
+		{"/src:", nil},
+		{":/dst", nil},
+		{"/dst:@", nil},
+		{"/dst:@../graph", nil},
+
+		// Relative paths and the root
+		{"src:/dst", nil},
+		{"/src:dst", nil},
+		{"dst:@data", nil},
+		{"/src:/", nil},
+		{"/src:/..", nil},
+		{"/:@data", nil},
+
+		// Bad and conflicting options
+		{"/src:/dst:", nil},
+		{"/src:/dst:bogus", nil},
+		{"/src:/dst:ro,rw", nil},
+		{"/src:/dst:ro,ro", nil},
+		{"/src:/dst:z,Z", nil},
+		{"/src:/dst:private,shared", nil},
+		{"/src:/dst:nocopy", nil},
+	}
+
+	for _, test := range tests {
+		bind, err := ParseBind(test.spec)
+
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("ParseBind(%q) = %+v, want an error", test.spec, bind)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseBind(%q) failed: %s", test.spec, err)
+			continue
+		}
+
+		if *bind != *test.want {
+			t.Errorf("ParseBind(%q) = %+v, want %+v", test.spec, bind, test.want)
+		}
+	}
+}
+
+func TestResolveMountpoint(t *testing.T) {
+	root, err := ioutil.TempDir("", "mounts-test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(root)
+
+	container := &Container{root: root}
+	rootfs := container.RootfsPath()
+
+	for _, dir := range []string{"data", "var/lib", "run"} {
+		if err := os.MkdirAll(path.Join(rootfs, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"var/run":   "/run",
+		"var/lock":  "../run/lock",
+		"shared":    "data",
+		"up":        "../../..",
+		"abs-up":    "/../..",
+		"var/up":    "../../outside",
+		"loop":      "loop",
+		"var/lib/x": "../../data/x",
+	}
+
+	for name, target := range links {
+		if err := os.Symlink(target, path.Join(rootfs, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dst  string
+		want string // "" when it must be refused
+	}{
+		{"/data", "/data"},
+		{"/missing/dir", "/missing/dir"},
+		{"/data/../run", "/run"},
+		{"/var/run", "/run"},
+		{"/var/run/app", "/run/app"},
+		{"/var/lock", "/run/lock"},
+		{"/var/lib/x/y", "/data/x/y"},
+
+		// Two destinations that end up on the same mountpoint, which Start
+		// refuses as a duplicate
+		{"/shared", "/data"},
+
+		{"/..", ""},
+		{"/data/../..", ""},
+		{"/up", ""},
+		{"/up/etc", ""},
+		{"/abs-up", ""},
+		{"/var/up", ""},
+		{"/loop", ""},
+	}
+
+	for _, test := range tests {
+		got, err := container.resolveMountpoint(test.dst)
+
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("resolveMountpoint(%q) = %q, want an error", test.dst, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("resolveMountpoint(%q) failed: %s", test.dst, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("resolveMountpoint(%q) = %q, want %q", test.dst, got, test.want)
+		}
+	}
+}
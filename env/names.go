@@ -0,0 +1,240 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// Container names are stored in DIR/names as a json map of name to
+// container id. All changes are made while holding a flock on names.lock.
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Names made only of hex digits would shadow id prefixes
+var hexName = regexp.MustCompile(`^[0-9a-f]+$`)
+
+var nameAdjectives = []string{
+	"agile", "bold", "brave", "bright", "calm", "clever", "cool", "crisp",
+	"eager", "fancy", "gentle", "happy", "hardy", "jolly", "keen", "kind",
+	"lively", "lucky", "mellow", "merry", "nimble", "proud", "quick", "quiet",
+	"rapid", "shiny", "silent", "steady", "sunny", "swift", "tidy", "witty",
+}
+
+var nameNouns = []string{
+	"badger", "beaver", "bison", "condor", "coyote", "falcon", "ferret", "gecko",
+	"heron", "ibis", "jackal", "koala", "lemur", "lynx", "marmot", "marten",
+	"moose", "narwhal", "ocelot", "otter", "panda", "puffin", "quail", "raven",
+	"salmon", "stoat", "tapir", "toucan", "walrus", "weasel", "wombat", "yak",
+}
+
+var nameRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+func namesPath() string {
+	return path.Join(DIR, "names")
+}
+
+func readNames() (map[string]string, error) {
+	names := make(map[string]string)
+
+	data, err := ioutil.ReadFile(namesPath())
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %s", namesPath(), err)
+	}
+
+	return names, nil
+}
+
+// Run fn with the name index locked and write back whatever it leaves
+// in names if it succeeds.
+func updateNames(fn func(names map[string]string) error) error {
+	lock, err := os.OpenFile(namesPath()+".lock", os.O_WRONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	defer lock.Close()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	names, err := readNames()
+
+	if err != nil {
+		return err
+	}
+
+	if err := fn(names); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(names)
+
+	if err != nil {
+		return err
+	}
+
+	return utils.AtomicWriteFile(namesPath(), data, 0644, false)
+}
+
+// ValidateName checks that name can be used for a container
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("Invalid container name '%s', only [a-zA-Z0-9][a-zA-Z0-9_.-]* are allowed", name)
+	}
+
+	if hexName.MatchString(name) {
+		return fmt.Errorf("Invalid container name '%s', it looks like a container id", name)
+	}
+
+	return nil
+}
+
+func generateName(names map[string]string) string {
+	for i := 0; i < 10; i++ {
+		name := nameAdjectives[nameRand.Intn(len(nameAdjectives))] + "_" +
+			nameNouns[nameRand.Intn(len(nameNouns))]
+
+		if _, taken := names[name]; !taken {
+			return name
+		}
+	}
+
+	// Most of the combinations are in use, fall back to a numbered one
+	base := nameAdjectives[nameRand.Intn(len(nameAdjectives))] + "_" +
+		nameNouns[nameRand.Intn(len(nameNouns))]
+
+	for n := 2; ; n++ {
+		name := base + strconv.Itoa(n)
+
+		if _, taken := names[name]; !taken {
+			return name
+		}
+	}
+}
+
+// Assign a name to the container id, generating one if name is empty
+func reserveName(name, id string) (string, error) {
+	if name != "" {
+		if err := ValidateName(name); err != nil {
+			return "", err
+		}
+	}
+
+	err := updateNames(func(names map[string]string) error {
+		if name == "" {
+			name = generateName(names)
+		} else if owner, taken := names[name]; taken {
+			return fmt.Errorf("The name '%s' is already used by container %s", name, utils.TruncateID(owner))
+		}
+
+		names[name] = id
+		return nil
+	})
+
+	return name, err
+}
+
+// Drop name from the index if it still belongs to the container id
+func releaseName(name, id string) error {
+	if name == "" {
+		return nil
+	}
+
+	return updateNames(func(names map[string]string) error {
+		if names[name] == id {
+			delete(names, name)
+		}
+		return nil
+	})
+}
+
+// Returns the name currently assigned to the container id
+func nameOf(id string) (string, bool) {
+	names, err := readNames()
+
+	if err != nil {
+		return "", false
+	}
+
+	for name, owner := range names {
+		if owner == id {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Rename changes the name of the container, updating both the name index
+// and the container's config.
+func (container *Container) Rename(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	err := updateNames(func(names map[string]string) error {
+		if owner, taken := names[name]; taken {
+			if owner == container.ID {
+				return nil
+			}
+			return fmt.Errorf("The name '%s' is already used by container %s", name, utils.TruncateID(owner))
+		}
+
+		if names[container.Name] == container.ID {
+			delete(names, container.Name)
+		}
+
+		names[name] = container.ID
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	container.Name = name
+
+	return container.ToDisk()
+}
+
+// ResolveContainer loads the container referred to by ref, which is either
+// a container name or a unique prefix of its id.
+func ResolveContainer(ref string) (*Container, error) {
+	names, err := readNames()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if id, ok := names[ref]; ok {
+		return LoadContainer(DIR, id)
+	}
+
+	id, err := utils.ExpandID(DIR, ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return LoadContainer(DIR, id)
+}
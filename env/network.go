@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -37,6 +38,24 @@ type NetworkSettings struct {
 
 var NetworkBridgeIface string = DefaultNetworkBridge
 
+// PortsString renders the port mappings like "49153->80/tcp, 49154->53/udp"
+func (settings *NetworkSettings) PortsString() string {
+	var ports []string
+
+	for _, proto := range []string{"Tcp", "Udp"} {
+		var mapped []string
+
+		for back, front := range settings.PortMapping[proto] {
+			mapped = append(mapped, fmt.Sprintf("%s->%s/%s", front, back, strings.ToLower(proto)))
+		}
+
+		sort.Strings(mapped)
+		ports = append(ports, mapped...)
+	}
+
+	return strings.Join(ports, ", ")
+}
+
 // Calculates the first and last IP addresses in an IPNet
 func networkRange(network *net.IPNet) (net.IP, net.IP) {
 	netIP := network.IP.To4()
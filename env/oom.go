@@ -0,0 +1,265 @@
+package env
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// How long to wait for lxc-start to move init into the container's cgroups
+const cgroupTimeout = 10 * time.Second
+
+// How often the peak memory use is sampled while the container runs
+const memorySampleInterval = time.Second
+
+// memoryWatch follows the memory cgroup of a running container, noting
+// whether the kernel OOM killed anything in it and the most memory it used.
+type memoryWatch struct {
+	cg     *cgroupDirs
+	file   *os.File    // the eventfd (v1) or inotify (v2) the kernel notifies on
+	events chan uint64 // what was read from file
+	stop   chan struct{}
+	done   chan struct{}
+
+	OOMKilled bool
+	Peak      uint64
+}
+
+// Returns the cgroups of the container's init. lxc-start forks init before
+// moving it into them, so wait until the memory cgroup is the container's.
+func (container *Container) initCgroups() (*cgroupDirs, error) {
+	deadline := time.Now().Add(cgroupTimeout)
+
+	for {
+		if !container.IsRunning() {
+			return nil, fmt.Errorf("Container %s is not running", utils.TruncateID(container.ID))
+		}
+
+		if pid, err := container.initPid(); err == nil {
+			if cg, err := cgroupsOf(pid); err == nil {
+				if dir, err := cg.path("memory"); err == nil && strings.Contains(dir, container.ID) {
+					return cg, nil
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("Timed out waiting for the cgroups of container %s", utils.TruncateID(container.ID))
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// Starts watching the memory cgroup of the running container. Call Stop
+// once it has exited to get the results. Without a memory controller, eg.
+// a v1 kernel booted without cgroup_enable=memory, there is nothing to
+// watch and it returns nil.
+func (container *Container) watchMemory() (*memoryWatch, error) {
+	if !DetectCapabilities().MemoryLimit {
+		return nil, nil
+	}
+
+	cg, err := container.initCgroups()
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &memoryWatch{
+		cg:     cg,
+		events: make(chan uint64, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if cg.v2 {
+		w.file, err = w.notifyV2()
+	} else {
+		w.file, err = w.notifyV1()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	go w.read()
+	go w.loop()
+
+	return w, nil
+}
+
+// v1 signals an eventfd registered against memory.oom_control through
+// cgroup.event_control. It is also signalled when the cgroup is removed.
+func (w *memoryWatch) notifyV1() (*os.File, error) {
+	dir, err := w.cg.path("memory")
+
+	if err != nil {
+		return nil, err
+	}
+
+	oomControl, err := os.Open(path.Join(dir, "memory.oom_control"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer oomControl.Close()
+
+	fd, _, errno := syscall.Syscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+
+	if errno != 0 {
+		return nil, errno
+	}
+
+	file := os.NewFile(fd, "eventfd")
+	reg := fmt.Sprintf("%d %d", fd, oomControl.Fd())
+
+	if err := ioutil.WriteFile(path.Join(dir, "cgroup.event_control"), []byte(reg), 0644); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// v2 has no eventfd, but memory.events is modified when its counters change
+func (w *memoryWatch) notifyV2() (*os.File, error) {
+	dir, err := w.cg.path("memory")
+
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+
+	if err != nil {
+		return nil, err
+	}
+
+	file := os.NewFile(uintptr(fd), "inotify")
+
+	if _, err := syscall.InotifyAddWatch(fd, path.Join(dir, "memory.events"), syscall.IN_MODIFY); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Forwards notifications until the file is closed or the cgroup goes away.
+// An eventfd read is the number of times it was signalled.
+func (w *memoryWatch) read() {
+	defer close(w.events)
+
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := w.file.Read(buf)
+
+		if err != nil {
+			return
+		}
+
+		count := uint64(1)
+
+		if !w.cg.v2 && n == 8 {
+			count = binary.LittleEndian.Uint64(buf)
+		}
+
+		w.events <- count
+	}
+}
+
+func (w *memoryWatch) loop() {
+	defer close(w.done)
+
+	w.sample()
+
+	tick := time.NewTicker(memorySampleInterval)
+	defer tick.Stop()
+
+	events := w.events
+
+	for {
+		select {
+		case count, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+
+			w.check(count)
+		case <-tick.C:
+			w.sample()
+		case <-w.stop:
+			// Pick up notifications that raced with the exit. The file is
+			// closed by now, so events is too once they are read.
+			if events != nil {
+				for count := range events {
+					w.check(count)
+				}
+			}
+
+			return
+		}
+	}
+}
+
+// Records the most memory used so far. memory.peak is only in newer v2
+// kernels, without it the peak is that of the samples.
+func (w *memoryWatch) sample() {
+	var peak uint64
+	var err error
+
+	if w.cg.v2 {
+		if peak, err = w.cg.readUint("", "memory.peak"); err != nil {
+			peak, err = w.cg.readUint("", "memory.current")
+		}
+	} else {
+		peak, err = w.cg.readUint("memory", "memory.max_usage_in_bytes")
+	}
+
+	if err == nil && peak > w.Peak {
+		w.Peak = peak
+	}
+}
+
+// Handles a notification from the kernel
+func (w *memoryWatch) check(count uint64) {
+	w.sample()
+
+	if w.cg.v2 {
+		if events, err := w.cg.readKeyValues("", "memory.events"); err == nil && events["oom_kill"] > 0 {
+			w.OOMKilled = true
+		}
+
+		return
+	}
+
+	// v1 notifies as the OOM killer is invoked, before oom_kill is counted.
+	// Removing the cgroup signals once too, so anything more, or a signal
+	// while it still exists, is an OOM.
+	if count > 1 {
+		w.OOMKilled = true
+		return
+	}
+
+	if _, err := w.cg.readFile("memory", "memory.oom_control"); err == nil {
+		w.OOMKilled = true
+	}
+}
+
+// Stop ends the watch once the container has exited
+func (w *memoryWatch) Stop() {
+	w.file.Close()
+	close(w.stop)
+	<-w.done
+}
@@ -0,0 +1,155 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// How long to wait for every process to be frozen
+const freezeTimeout = 10 * time.Second
+
+// Freeze or thaw the processes of the container and wait until the kernel
+// reports it done. v1 has the freezer controller, v2 cgroup.freeze.
+func (container *Container) setFrozen(frozen bool) error {
+	cg, err := container.cgroups()
+
+	if err != nil {
+		return err
+	}
+
+	dir, err := cg.path("freezer")
+
+	if err != nil {
+		return err
+	}
+
+	var file, value, stateFile, want string
+
+	if cg.v2 {
+		file, stateFile = "cgroup.freeze", "cgroup.events"
+		value, want = "0", "frozen 0"
+
+		if frozen {
+			value, want = "1", "frozen 1"
+		}
+	} else {
+		file, stateFile = "freezer.state", "freezer.state"
+		value, want = "THAWED", "THAWED"
+
+		if frozen {
+			value, want = "FROZEN", "FROZEN"
+		}
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, file), []byte(value), 0644); err != nil {
+		return err
+	}
+
+	// v1 goes through FREEZING, and v2 only sets frozen 1 in cgroup.events
+	// once every process has stopped
+	deadline := time.Now().Add(freezeTimeout)
+
+	for {
+		data, err := ioutil.ReadFile(path.Join(dir, stateFile))
+
+		if err != nil {
+			return err
+		}
+
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.TrimSpace(line) == want {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out waiting for the container to reach %s", value)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Reports whether the container's cgroup is frozen, or being frozen
+func (container *Container) frozen() (bool, error) {
+	cg, err := container.cgroups()
+
+	if err != nil {
+		return false, err
+	}
+
+	if cg.v2 {
+		val, err := cg.readFile("", "cgroup.freeze")
+		return val == "1", err
+	}
+
+	val, err := cg.readFile("freezer", "freezer.state")
+	return val != "THAWED", err
+}
+
+// Thaws the container if it is paused. It may have been paused by another
+// process, so the freezer is asked rather than State, and the state on
+// disk is updated rather than written over with this copy.
+func (container *Container) thaw() error {
+	if frozen, err := container.frozen(); err != nil || !frozen {
+		return nil
+	}
+
+	if err := container.setFrozen(false); err != nil {
+		return err
+	}
+
+	container.State.Paused = false
+
+	if saved, err := LoadContainer(DIR, container.ID); err == nil && saved.State.Paused {
+		saved.State.Paused = false
+		return saved.ToDisk()
+	}
+
+	return nil
+}
+
+// Pause freezes every process in the running container
+func (container *Container) Pause() error {
+	if !container.IsRunning() {
+		return fmt.Errorf("Container %s is not running", utils.TruncateID(container.ID))
+	}
+
+	if container.State.Paused {
+		return fmt.Errorf("Container %s is already paused", utils.TruncateID(container.ID))
+	}
+
+	if err := container.setFrozen(true); err != nil {
+		// Don't leave it half frozen
+		container.setFrozen(false)
+		return err
+	}
+
+	container.State.Paused = true
+
+	return container.ToDisk()
+}
+
+// Unpause lets the processes of a paused container run again
+func (container *Container) Unpause() error {
+	if !container.IsRunning() {
+		return fmt.Errorf("Container %s is not running", utils.TruncateID(container.ID))
+	}
+
+	if !container.State.Paused {
+		return fmt.Errorf("Container %s is not paused", utils.TruncateID(container.ID))
+	}
+
+	if err := container.setFrozen(false); err != nil {
+		return err
+	}
+
+	container.State.Paused = false
+
+	return container.ToDisk()
+}
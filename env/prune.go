@@ -0,0 +1,194 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// Prefixes used for the graph directories of in progress imports and commits
+const (
+	importTmpPrefix = ":artmp:"
+	commitTmpPrefix = "_armktmp-"
+)
+
+// How old things must be before prune removes them by default. Temp dirs
+// younger than this may belong to an import, commit or build still going.
+const DefaultPruneAge = 24 * time.Hour
+
+// PruneReport describes what a prune will remove and how much space that
+// will reclaim.
+type PruneReport struct {
+	Containers []*Container
+	Images     []*Image
+	TempDirs   []string
+	Size       int64
+}
+
+// Reachable returns the ids of every image referenced by a tag or by one
+// of the given containers, along with all of their parents.
+func (ts *TagStore) Reachable(conts []*Container) map[string]bool {
+	reach := make(map[string]bool)
+
+	mark := func(id string) {
+		img, ok := ts.Entries[id]
+
+		if !ok {
+			reach[id] = true
+			return
+		}
+
+		for _, cur := range img.chain() {
+			reach[cur.ID] = true
+		}
+	}
+
+	for _, tags := range ts.Repositories {
+		for _, id := range tags {
+			mark(id)
+		}
+	}
+
+	for _, cont := range conts {
+		if cont.Image != "" {
+			mark(cont.Image)
+		}
+	}
+
+	return reach
+}
+
+// PlanPrune works out which stopped containers, unreachable images and
+// temporary directories older than olderThan can be removed. If
+// labels is given only containers and images matching every label selector
+// are included.
+func PlanPrune(ts *TagStore, olderThan time.Duration, labels []string) (*PruneReport, error) {
+	conts, err := LoadContainers(DIR)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PruneReport{}
+	cutoff := time.Now().Add(-olderThan)
+
+	var keep []*Container
+
+	for _, cont := range conts {
+		last := cont.Created
+		if cont.State.StartedAt.After(last) {
+			last = cont.State.StartedAt
+		}
+
+		if cont.IsRunning() || last.After(cutoff) || !MatchLabels(cont.Labels(), labels) {
+			keep = append(keep, cont)
+			continue
+		}
+
+		report.Containers = append(report.Containers, cont)
+
+		if sz, err := utils.TreeSize(cont.root); err == nil {
+			report.Size += sz
+		}
+	}
+
+	reach := ts.Reachable(keep)
+
+	for id, img := range ts.Entries {
+		if reach[id] || !MatchLabels(img.Labels(), labels) {
+			continue
+		}
+
+		// A build or import may have just written it and not tagged it
+		// yet. Imports keep the original creation time, so the graph dir
+		// is checked too.
+		if img.Created.After(cutoff) {
+			continue
+		}
+
+		if fi, err := os.Stat(path.Join(DIR, "graph", id)); err != nil || fi.ModTime().After(cutoff) {
+			continue
+		}
+
+		report.Images = append(report.Images, img)
+
+		if sz, err := utils.TreeSize(path.Join(DIR, "graph", id)); err == nil {
+			report.Size += sz
+		}
+	}
+
+	dirs, err := ioutil.ReadDir(path.Join(DIR, "graph"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range dirs {
+		name := f.Name()
+
+		if !strings.HasPrefix(name, importTmpPrefix) && !strings.HasPrefix(name, commitTmpPrefix) {
+			continue
+		}
+
+		// Temp dirs have no labels to match against
+		if len(labels) > 0 {
+			continue
+		}
+
+		if f.ModTime().After(cutoff) {
+			continue
+		}
+
+		pth := path.Join(DIR, "graph", name)
+
+		report.TempDirs = append(report.TempDirs, pth)
+
+		if sz, err := utils.TreeSize(pth); err == nil {
+			report.Size += sz
+		}
+	}
+
+	return report, nil
+}
+
+// Apply removes everything listed in the report. Tags are not touched,
+// since only unreferenced images are ever included.
+func (report *PruneReport) Apply() error {
+	var failed []string
+
+	for _, cont := range report.Containers {
+		logv("Removing container %s", utils.TruncateID(cont.ID))
+
+		if err := cont.Remove(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", utils.TruncateID(cont.ID), err))
+		}
+	}
+
+	// The images may still be used by what is left
+	if len(failed) > 0 {
+		return fmt.Errorf("Unable to remove containers %s, no images were removed", strings.Join(failed, ", "))
+	}
+
+	for _, img := range report.Images {
+		logv("Removing image %s", utils.TruncateID(img.ID))
+
+		if err := img.Remove(); err != nil {
+			return err
+		}
+	}
+
+	for _, dir := range report.TempDirs {
+		logv("Removing %s", dir)
+
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
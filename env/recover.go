@@ -0,0 +1,213 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/vektra/container/utils"
+)
+
+// Marker written to RUN_DIR once recovery has run. RUN_DIR lives on a tmpfs
+// so the marker going missing means the host has rebooted.
+const bootMarker = ".recovered"
+
+// Report whether pid is still an lxc-start process, guarding against the
+// pid having been reused since it was recorded.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(string(data), "lxc-start")
+}
+
+// Returns the pid recorded in the container's running file, or 0
+func (container *Container) runningPid() int {
+	data, err := ioutil.ReadFile(container.PathTo("running"))
+
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+// Recover finds containers that are marked as running but whose process
+// has gone away, and releases everything they were holding: the rootfs
+// mount, their IP and ports, DNAT rules and advertisements. The recovered
+// containers are returned.
+func Recover() ([]*Container, error) {
+	conts, err := LoadContainers(DIR)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []*Container
+
+	alloc := newNetAllocator(nil)
+	mapper := &PortMapper{}
+
+	for _, cont := range conts {
+		if _, err := os.Stat(cont.PathTo("running")); err != nil {
+			continue
+		}
+
+		pid := cont.runningPid()
+
+		if processAlive(pid) {
+			continue
+		}
+
+		logv("Recovering %s (pid %d is gone)", utils.TruncateID(cont.ID), pid)
+
+		if mounted, _ := cont.Mounted(); mounted {
+			if err := cont.Unmount(); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to unmount %s: %s\n", cont.RootfsPath(), err)
+			}
+		}
+
+		cont.releaseNetwork(alloc, mapper)
+
+		if pid != 0 {
+			cont.State.Pid = pid
+		}
+
+		cont.setStopped(-1)
+
+		os.Remove(cont.PathTo("running"))
+
+		if hc, err := cont.ReadHostConfig(); err == nil && !hc.Save {
+			cont.Remove()
+		} else {
+			cont.ToDisk()
+		}
+
+		recovered = append(recovered, cont)
+	}
+
+	removeStaleAdvertisements()
+	removeStaleForwards(alloc, mapper)
+
+	ioutil.WriteFile(path.Join(RUN_DIR, bootMarker), []byte{}, 0644)
+
+	return recovered, nil
+}
+
+// Release the IP and ports recorded in the container's network settings
+// along with the DNAT rules that forwarded to them.
+func (container *Container) releaseNetwork(alloc *NetAllocator, mapper *PortMapper) {
+	ns := container.NetworkSettings
+
+	if ns == nil || ns.IPAddress == "" {
+		return
+	}
+
+	for proto, ports := range ns.PortMapping {
+		proto = strings.ToLower(proto)
+
+		for back, front := range ports {
+			bp, _ := strconv.Atoi(back)
+			fp, _ := strconv.Atoi(front)
+
+			mapper.iptablesForward("-D", fp, proto, ns.IPAddress, bp)
+
+			if proto == "tcp" {
+				alloc.ReleaseTCPPort(fp)
+			} else {
+				alloc.ReleaseUDPPort(fp)
+			}
+		}
+	}
+
+	alloc.Release(net.ParseIP(ns.IPAddress))
+}
+
+// Remove RUN_DIR and INIT_DIR entries for processes that no longer exist
+func removeStaleAdvertisements() {
+	ents, err := ioutil.ReadDir(RUN_DIR)
+
+	if err != nil {
+		return
+	}
+
+	for _, ent := range ents {
+		pid, err := strconv.Atoi(ent.Name())
+
+		if err != nil || processAlive(pid) {
+			continue
+		}
+
+		os.RemoveAll(path.Join(RUN_DIR, ent.Name()))
+		os.RemoveAll(path.Join(INIT_DIR, ent.Name()))
+	}
+}
+
+// Delete DNAT rules in the AR chain that point at IPs which are no longer
+// allocated to any container.
+func removeStaleForwards(alloc *NetAllocator, mapper *PortMapper) {
+	out, err := utils.RunUnchecked("iptables", "-t", "nat", "-S", "AR")
+
+	if err != nil {
+		return
+	}
+
+	alloc.LockAndLoad()
+	defer alloc.Unlock()
+
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+
+		for i, f := range fields {
+			if f != "--to-destination" || i+1 >= len(fields) {
+				continue
+			}
+
+			host, _, err := net.SplitHostPort(fields[i+1])
+
+			if err != nil {
+				continue
+			}
+
+			if _, ok := alloc.cfg.IPs[host]; ok {
+				continue
+			}
+
+			logv("Removing stale forward to %s", fields[i+1])
+
+			fields[0] = "-D"
+			iptables(append([]string{"-t", "nat"}, fields...)...)
+		}
+	}
+}
+
+// Run recovery if the host has rebooted since it was last done
+func recoverAfterReboot() error {
+	if _, err := os.Stat(path.Join(RUN_DIR, bootMarker)); err == nil {
+		return nil
+	}
+
+	_, err := Recover()
+	return err
+}
@@ -34,7 +34,12 @@ func Init() error { // Not auto-run on purpose.
 		}
 	}
 
-	return nil
+	if err := Migrate(); err != nil {
+		return err
+	}
+
+	// Clean up after containers that were running when the host went down
+	return recoverAfterReboot()
 }
 
 var Verbose bool
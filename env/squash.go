@@ -0,0 +1,216 @@
+package env
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// AUFS marks deleted files in an upper branch with a .wh.<name> entry, and
+// directories whose lower contents are hidden with a .wh..wh..opq entry.
+const (
+	whiteoutPrefix     = ".wh."
+	whiteoutMetaPrefix = ".wh..wh."
+	whiteoutOpaqueDir  = ".wh..wh..opq"
+)
+
+func isWhiteout(name string) bool {
+	return strings.HasPrefix(name, whiteoutPrefix)
+}
+
+// Squash flattens the image and all of its parents into a single layer,
+// applying whiteouts on the way. The new image has no parent, keeps the
+// config of the top image and records the images it replaced in History.
+func (image *Image) Squash(comment, author string, squashfs bool) (*Image, error) {
+	chain := image.chain()
+
+	img := &Image{
+		ID:              utils.GenerateID(),
+		Comment:         comment,
+		Created:         time.Now(),
+		Container:       image.Container,
+		ContainerConfig: image.ContainerConfig,
+		Author:          author,
+		Config:          image.Config,
+		Architecture:    image.Architecture,
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		cur := chain[i]
+
+		if len(cur.History) > 0 {
+			img.History = append(img.History, cur.History...)
+		} else {
+			img.History = append(img.History, ImageHistory{
+				ID:      cur.ID,
+				Created: cur.Created,
+				Author:  cur.Author,
+				Comment: cur.Comment,
+			})
+		}
+	}
+
+	logv("Squashing %d layers into %s", len(chain), utils.TruncateID(img.ID))
+
+	root := path.Join(DIR, "graph", commitTmpPrefix+img.ID)
+
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	layerPath := path.Join(root, "layer")
+
+	flat := layerPath
+	if squashfs {
+		flat = path.Join(root, "flat")
+	}
+
+	if err := os.MkdirAll(flat, 0755); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		lp, err := chain[i].mountLayer()
+
+		if err != nil {
+			os.RemoveAll(root)
+			return nil, err
+		}
+
+		logv("Applying layer %s...", utils.TruncateID(chain[i].ID))
+
+		if err := applyLayer(lp, flat); err != nil {
+			os.RemoveAll(root)
+			return nil, err
+		}
+	}
+
+	if squashfs {
+		logv("Generating squashfs...")
+
+		utils.Run("mksquashfs", flat, path.Join(root, "layer.fs"), "-comp", "xz")
+
+		os.RemoveAll(flat)
+		os.MkdirAll(layerPath, 0755)
+	}
+
+	img.Size = LayerSizeAt(root)
+
+	jsonData, err := json.Marshal(img)
+
+	if err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(path.Join(root, "json"), jsonData, 0644); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	if err := os.Rename(root, path.Join(DIR, "graph", img.ID)); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// Copy the contents of layer on top of dst, first removing anything in dst
+// that the layer whites out or replaces with an entry of a different type.
+func applyLayer(layer, dst string) error {
+	err := filepath.Walk(layer, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(layer, p)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		name := fi.Name()
+		target := path.Join(dst, rel)
+
+		switch {
+		case name == whiteoutOpaqueDir:
+			entries, _ := ioutil.ReadDir(path.Dir(target))
+			for _, ent := range entries {
+				if err := os.RemoveAll(path.Join(path.Dir(target), ent.Name())); err != nil {
+					return err
+				}
+			}
+		case strings.HasPrefix(name, whiteoutMetaPrefix):
+			// AUFS bookkeeping (.wh..wh.plnk, .wh..wh.aufs), never user data
+		case isWhiteout(name):
+			if err := os.RemoveAll(path.Join(path.Dir(target), name[len(whiteoutPrefix):])); err != nil {
+				return err
+			}
+		default:
+			if cur, err := os.Lstat(target); err == nil && (cur.IsDir() != fi.IsDir() || !fi.IsDir()) {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	utils.Run("cp", "-a", layer+"/.", dst)
+
+	return removeWhiteouts(dst)
+}
+
+// Remove any whiteout entries left in a flattened tree
+func removeWhiteouts(dir string) error {
+	var found []string
+
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if isWhiteout(fi.Name()) {
+			found = append(found, p)
+
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range found {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
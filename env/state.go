@@ -13,6 +13,10 @@ type State struct {
 	ExitCode  int
 	StartedAt time.Time
 	Ghost     bool
+	Paused    bool
+	OOMKilled bool
+	// The most memory used by the last run, in bytes
+	MemoryPeak uint64
 }
 
 // String returns a human-readable description of the state
@@ -21,14 +25,22 @@ func (s *State) String() string {
 		if s.Ghost {
 			return fmt.Sprintf("Ghost")
 		}
+		if s.Paused {
+			return fmt.Sprintf("Up %s (Paused)", HumanDuration(time.Now().Sub(s.StartedAt)))
+		}
 		return fmt.Sprintf("Up %s", HumanDuration(time.Now().Sub(s.StartedAt)))
 	}
+	if s.OOMKilled {
+		return fmt.Sprintf("Exit %d (OOM killed)", s.ExitCode)
+	}
 	return fmt.Sprintf("Exit %d", s.ExitCode)
 }
 
 func (s *State) setRunning(pid int) {
 	s.Running = true
 	s.Ghost = false
+	s.OOMKilled = false
+	s.MemoryPeak = 0
 	s.ExitCode = 0
 	s.Pid = pid
 	s.StartedAt = time.Now()
@@ -36,6 +48,7 @@ func (s *State) setRunning(pid int) {
 
 func (s *State) setStopped(exitCode int) {
 	s.Running = false
+	s.Paused = false
 	s.Pid = 0
 	s.ExitCode = exitCode
 }
@@ -0,0 +1,210 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats is a snapshot of the resources a running container is using, read
+// from its cgroups and the network counters inside it.
+type Stats struct {
+	Read        time.Time
+	CPUUsage    uint64 // CPU time used so far, in nanoseconds
+	MemoryUsage uint64 // Memory in use, not counting inactive page cache
+	MemoryLimit uint64 // 0 when there is no limit
+	Pids        uint64
+	BlockRead   uint64
+	BlockWrite  uint64
+	NetRx       uint64
+	NetTx       uint64
+}
+
+// CPUPercent returns the CPU used between prev and s as a percentage of a
+// single CPU, so a container keeping two CPUs busy is at 200%.
+func (s *Stats) CPUPercent(prev *Stats) float64 {
+	if prev == nil || s.CPUUsage < prev.CPUUsage {
+		return 0
+	}
+
+	elapsed := s.Read.Sub(prev.Read)
+
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(s.CPUUsage-prev.CPUUsage) / float64(elapsed.Nanoseconds()) * 100
+}
+
+// MemoryPercent returns the memory in use as a percentage of the limit, or
+// 0 when there is no limit
+func (s *Stats) MemoryPercent() float64 {
+	if s.MemoryLimit == 0 {
+		return 0
+	}
+
+	return float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+}
+
+// Stats returns the current resource usage of the running container
+func (container *Container) Stats() (*Stats, error) {
+	pid, err := container.initPid()
+
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := cgroupsOf(pid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stats{Read: time.Now()}
+
+	if cg.v2 {
+		err = s.readCgroup2(cg)
+	} else {
+		err = s.readCgroup1(cg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.NetRx, s.NetTx, err = readNetDev(pid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Stats) readCgroup1(cg *cgroupDirs) error {
+	var err error
+
+	if s.CPUUsage, err = cg.readUint("cpuacct", "cpuacct.usage"); err != nil {
+		return err
+	}
+
+	if s.MemoryUsage, err = cg.readUint("memory", "memory.usage_in_bytes"); err != nil {
+		return err
+	}
+
+	if mem, err := cg.readKeyValues("memory", "memory.stat"); err == nil && mem["total_inactive_file"] < s.MemoryUsage {
+		s.MemoryUsage -= mem["total_inactive_file"]
+	}
+
+	// An unlimited v1 cgroup reports a huge number rather than nothing
+	if limit, err := cg.readUint("memory", "memory.limit_in_bytes"); err == nil && limit < 1<<62 {
+		s.MemoryLimit = limit
+	}
+
+	s.Pids, _ = cg.readUint("pids", "pids.current")
+
+	// Lines like: 8:0 Read 4096
+	if data, err := cg.readFile("blkio", "blkio.throttle.io_service_bytes"); err == nil {
+		for _, line := range strings.Split(data, "\n") {
+			fields := strings.Fields(line)
+
+			if len(fields) != 3 {
+				continue
+			}
+
+			v, _ := strconv.ParseUint(fields[2], 10, 64)
+
+			switch fields[1] {
+			case "Read":
+				s.BlockRead += v
+			case "Write":
+				s.BlockWrite += v
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Stats) readCgroup2(cg *cgroupDirs) error {
+	cpu, err := cg.readKeyValues("", "cpu.stat")
+
+	if err != nil {
+		return err
+	}
+
+	s.CPUUsage = cpu["usage_usec"] * 1000
+
+	if s.MemoryUsage, err = cg.readUint("", "memory.current"); err != nil {
+		return err
+	}
+
+	if mem, err := cg.readKeyValues("", "memory.stat"); err == nil && mem["inactive_file"] < s.MemoryUsage {
+		s.MemoryUsage -= mem["inactive_file"]
+	}
+
+	s.MemoryLimit, _ = cg.readUint("", "memory.max")
+	s.Pids, _ = cg.readUint("", "pids.current")
+
+	// Lines like: 8:0 rbytes=4096 wbytes=0 rios=1 wios=0 ...
+	if data, err := cg.readFile("", "io.stat"); err == nil {
+		for _, line := range strings.Split(data, "\n") {
+			for _, field := range strings.Fields(line) {
+				kv := strings.SplitN(field, "=", 2)
+
+				if len(kv) != 2 {
+					continue
+				}
+
+				v, _ := strconv.ParseUint(kv[1], 10, 64)
+
+				switch kv[0] {
+				case "rbytes":
+					s.BlockRead += v
+				case "wbytes":
+					s.BlockWrite += v
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Totals the bytes received and sent on the interfaces in the network
+// namespace of pid, leaving out loopback.
+func readNetDev(pid int) (uint64, uint64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/net/dev", pid))
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var rx, tx uint64
+
+	// After two header lines: "  eth0: rxbytes rxpackets ... (8 rx fields) txbytes ..."
+	for _, line := range strings.Split(string(data), "\n") {
+		n := strings.Index(line, ":")
+
+		if n < 0 {
+			continue
+		}
+
+		iface := strings.TrimSpace(line[:n])
+		fields := strings.Fields(line[n+1:])
+
+		if iface == "lo" || len(fields) < 9 {
+			continue
+		}
+
+		r, _ := strconv.ParseUint(fields[0], 10, 64)
+		t, _ := strconv.ParseUint(fields[8], 10, 64)
+
+		rx += r
+		tx += t
+	}
+
+	return rx, tx, nil
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"syscall"
 
 	"github.com/vektra/container/utils"
 )
@@ -22,6 +23,8 @@ type TagStore struct {
 	Path         string  `json:"-"`
 	Entries      Entries `json:"-"`
 	Repositories map[string]Repository
+
+	lock *os.File
 }
 
 func (i *TagStore) CopyTo(o *TagStore, id string, clobber bool) {
@@ -98,6 +101,30 @@ func (store *TagStore) LookupImage(name string) (*Image, error) {
 	return img, nil
 }
 
+// ResolveImage finds an image by repo:tag, falling back to treating name
+// as an image id or unique id prefix.
+func (store *TagStore) ResolveImage(name string) (*Image, error) {
+	img, err := store.LookupImage(name)
+
+	if err == nil {
+		return img, nil
+	}
+
+	id, ok := SafelyExpandImageID(name)
+
+	if !ok {
+		return nil, err
+	}
+
+	img, ok = store.Entries[id]
+
+	if !ok {
+		return nil, fmt.Errorf("Image not on disk")
+	}
+
+	return img, nil
+}
+
 func (store *TagStore) UsedAsParent(id string) bool {
 	for _, img := range store.Entries {
 		if img.Parent == id {
@@ -191,7 +218,74 @@ func (ts *TagStore) Flush() error {
 		return err
 	}
 
-	return ioutil.WriteFile(ts.Path, data, 0644)
+	return utils.AtomicWriteFile(ts.Path, data, 0644, true)
+}
+
+// Lock takes an exclusive lock on the tag store so that other processes
+// can't modify it until Unlock is called.
+func (ts *TagStore) Lock() error {
+	if ts.Path == "" {
+		return errors.New("No path set on tag store to lock")
+	}
+
+	lock, err := os.OpenFile(ts.Path+".lock", os.O_WRONLY|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX)
+
+	if err != nil {
+		lock.Close()
+		return err
+	}
+
+	ts.lock = lock
+
+	return nil
+}
+
+func (ts *TagStore) Unlock() {
+	if ts.lock != nil {
+		ts.lock.Close()
+		ts.lock = nil
+	}
+}
+
+// Reload rereads the repositories from disk, discarding any changes that
+// have not been flushed. Image entries are left alone.
+func (ts *TagStore) Reload() error {
+	cur, err := ReadRepoFile(ts.Path)
+
+	if err != nil {
+		return err
+	}
+
+	ts.Repositories = cur.Repositories
+
+	return nil
+}
+
+// Update locks the tag store, reloads it, runs fn against it and then
+// flushes the result. Use this rather than Flush so that concurrent
+// commands don't overwrite each others tags.
+func (ts *TagStore) Update(fn func(*TagStore) error) error {
+	if err := ts.Lock(); err != nil {
+		return err
+	}
+
+	defer ts.Unlock()
+
+	if err := ts.Reload(); err != nil {
+		return err
+	}
+
+	if err := fn(ts); err != nil {
+		return err
+	}
+
+	return ts.Flush()
 }
 
 // Get a repos name and returns the right reposName + tag
@@ -209,7 +303,24 @@ func ParseRepositoryTag(repos string) (string, string) {
 }
 
 func ReadRepoFile(path string) (*TagStore, error) {
-	data, err := ioutil.ReadFile(path)
+	tags, err := readRepoFile(path, path)
+
+	if err != nil {
+		bak, berr := readRepoFile(path, path+".bak")
+
+		if berr != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "Unable to read %s (%s), using backup copy\n", path, err)
+		tags = bak
+	}
+
+	return tags, nil
+}
+
+func readRepoFile(path, from string) (*TagStore, error) {
+	data, err := ioutil.ReadFile(from)
 
 	if err != nil {
 		return nil, err
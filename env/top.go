@@ -0,0 +1,130 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Process is a process running inside a container
+type Process struct {
+	Pid          int // as seen from the host
+	ContainerPid int // as seen inside the container
+}
+
+// Collects the pids in cgroup.procs of dir and of every cgroup below it,
+// since whatever runs in the container may make cgroups of its own.
+func cgroupProcs(dir string) ([]int, error) {
+	data, err := ioutil.ReadFile(path.Join(dir, "cgroup.procs"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []int
+
+	for _, line := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(line); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+
+	ents, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ent := range ents {
+		if !ent.IsDir() {
+			continue
+		}
+
+		sub, err := cgroupProcs(path.Join(dir, ent.Name()))
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		pids = append(pids, sub...)
+	}
+
+	return pids, nil
+}
+
+// Returns the pid of the host process pid inside its own pid namespace,
+// the last entry of NSpid in /proc/<pid>/status.
+func namespacePid(pid int) (int, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "NSpid:") {
+			continue
+		}
+
+		fields := strings.Fields(line[len("NSpid:"):])
+
+		if len(fields) == 0 {
+			break
+		}
+
+		return strconv.Atoi(fields[len(fields)-1])
+	}
+
+	return 0, fmt.Errorf("No NSpid for process %d, the kernel may be too old", pid)
+}
+
+// Processes returns every process in the running container, sorted by
+// host pid
+func (container *Container) Processes() ([]Process, error) {
+	cg, err := container.cgroups()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Any controller holds all the processes, pids is the most likely to
+	// be there on v1
+	var dir string
+
+	for _, ctrl := range []string{"pids", "memory", "cpu", "freezer"} {
+		if dir, err = cg.path(ctrl); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	pids, err := cgroupProcs(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Ints(pids)
+
+	var procs []Process
+
+	for _, pid := range pids {
+		cpid, err := namespacePid(pid)
+
+		// It has exited since cgroup.procs was read
+		if err != nil && os.IsNotExist(err) {
+			continue
+		}
+
+		procs = append(procs, Process{Pid: pid, ContainerPid: cpid})
+	}
+
+	return procs, nil
+}
@@ -0,0 +1,72 @@
+package env
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/vektra/container/utils"
+)
+
+// LayerSize measures the disk space used by the image's own layer, which is
+// either the squashfs file or the layer directory.
+func (image *Image) LayerSize() int64 {
+	return LayerSizeAt(path.Join(DIR, "graph", image.ID))
+}
+
+// LayerSizeAt measures the layer of the image stored in the graph directory
+// root.
+func LayerSizeAt(root string) int64 {
+	if fi, err := os.Stat(path.Join(root, "layer.fs")); err == nil {
+		return fi.Size()
+	}
+
+	sz, _ := utils.TreeSize(path.Join(root, "layer"))
+	return sz
+}
+
+// VirtualSize is the size of the image including all of its parents.
+func (image *Image) VirtualSize() int64 {
+	var total int64
+
+	for _, cur := range image.chain() {
+		total += cur.Size
+	}
+
+	return total
+}
+
+// FillSizes measures any images whose Size was not recorded when they
+// were created.
+func (ts *TagStore) FillSizes() {
+	for _, img := range ts.Entries {
+		if img.Size == 0 {
+			img.Size = img.LayerSize()
+		}
+	}
+}
+
+// RWSize returns the disk space used by the container's rw branch.
+func (container *Container) RWSize() int64 {
+	sz, _ := utils.TreeSize(container.rwPath())
+	return sz
+}
+
+// VolumeUsers maps each named volume to the containers that mount it.
+func VolumeUsers(conts []*Container) map[string][]*Container {
+	users := make(map[string][]*Container)
+	prefix := path.Join(DIR, "volumes") + "/"
+
+	for _, cont := range conts {
+		for _, src := range cont.Volumes {
+			if !strings.HasPrefix(src, prefix) {
+				continue
+			}
+
+			name := strings.SplitN(src[len(prefix):], "/", 2)[0]
+			users[name] = append(users[name], cont)
+		}
+	}
+
+	return users
+}
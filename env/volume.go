@@ -0,0 +1,254 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// VolumeInfo is the metadata kept for a named volume. It lives in
+// DIR/volume-info rather than next to the data so that nothing inside
+// DIR/volumes/<name> is visible to containers except what they put there.
+type VolumeInfo struct {
+	Name    string
+	Driver  string
+	Created time.Time
+	Options map[string]string `json:",omitempty"`
+	Labels  map[string]string `json:",omitempty"`
+}
+
+// VolumePath returns the directory holding the named volume's data
+func VolumePath(name string) string {
+	return path.Join(DIR, "volumes", name)
+}
+
+// Volume names end up in paths, so anything else, like ../graph, must be
+// refused before one is built
+func checkVolumeName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("Invalid volume name '%s', only [a-zA-Z0-9][a-zA-Z0-9_.-]* are allowed", name)
+	}
+
+	return nil
+}
+
+func volumeInfoPath(name string) string {
+	return path.Join(DIR, "volume-info", name+".json")
+}
+
+// LoadVolumeInfo returns the metadata for the named volume. Volumes
+// created implicitly by run -v before volumes had metadata have none
+// recorded, so a local one is made up from the directory.
+func LoadVolumeInfo(name string) (*VolumeInfo, error) {
+	if err := checkVolumeName(name); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(volumeInfoPath(name))
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		fi, err := os.Stat(VolumePath(name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &VolumeInfo{Name: name, Driver: "local", Created: fi.ModTime()}, nil
+	}
+
+	info := &VolumeInfo{}
+
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+
+	info.Name = name
+
+	if info.Driver == "" {
+		info.Driver = "local"
+	}
+
+	return info, nil
+}
+
+// ListVolumes returns every named volume, sorted by name
+func ListVolumes() ([]*VolumeInfo, error) {
+	dirs, err := ioutil.ReadDir(path.Join(DIR, "volumes"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var vols []*VolumeInfo
+
+	for _, d := range dirs {
+		info, err := LoadVolumeInfo(d.Name())
+
+		if err != nil {
+			return nil, fmt.Errorf("Error reading volume %s: %s", d.Name(), err)
+		}
+
+		vols = append(vols, info)
+	}
+
+	sort.Sort(volumesByName(vols))
+
+	return vols, nil
+}
+
+type volumesByName []*VolumeInfo
+
+func (v volumesByName) Len() int           { return len(v) }
+func (v volumesByName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v volumesByName) Less(i, j int) bool { return v[i].Name < v[j].Name }
+
+// Path returns the directory containers see as the volume
+func (info *VolumeInfo) Path() string {
+	return VolumePath(info.Name)
+}
+
+// Size returns the disk space used by the volume's data
+func (info *VolumeInfo) Size() int64 {
+	sz, _ := utils.TreeSize(info.Path())
+	return sz
+}
+
+// Save writes the volume metadata to disk
+func (info *VolumeInfo) Save() error {
+	data, err := json.Marshal(info)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(volumeInfoPath(info.Name)), 0755); err != nil {
+		return err
+	}
+
+	return utils.AtomicWriteFile(volumeInfoPath(info.Name), data, 0644, false)
+}
+
+func (info *VolumeInfo) driver() (VolumeDriver, error) {
+	drv, ok := volumeDrivers[info.Driver]
+
+	if !ok {
+		return nil, fmt.Errorf("Unknown volume driver '%s'", info.Driver)
+	}
+
+	return drv, nil
+}
+
+// CreateVolume makes a new named volume using driver, which is given opts
+func CreateVolume(name, driver string, opts, labels map[string]string) (*VolumeInfo, error) {
+	if err := checkVolumeName(name); err != nil {
+		return nil, err
+	}
+
+	if driver == "" {
+		driver = "local"
+	}
+
+	info := &VolumeInfo{
+		Name:    name,
+		Driver:  driver,
+		Created: time.Now(),
+		Options: opts,
+		Labels:  labels,
+	}
+
+	drv, err := info.driver()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := drv.Validate(info); err != nil {
+		return nil, err
+	}
+
+	if err := os.Mkdir(VolumePath(name), 0755); err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("Volume %s already exists", name)
+		}
+		return nil, err
+	}
+
+	if err := drv.Create(info); err != nil {
+		os.Remove(VolumePath(name))
+		return nil, err
+	}
+
+	if err := info.Save(); err != nil {
+		drv.Remove(info)
+		os.Remove(VolumePath(name))
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// PrepareVolume makes the named volume ready to be mounted into a
+// container and returns its path. Unknown volumes are created as local
+// ones, which is how run -v /path:@name has always behaved.
+func PrepareVolume(name string) (string, error) {
+	info, err := LoadVolumeInfo(name)
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		if info, err = CreateVolume(name, "local", nil, nil); err != nil {
+			return "", err
+		}
+	}
+
+	drv, err := info.driver()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := drv.Mount(info); err != nil {
+		return "", fmt.Errorf("Unable to mount volume %s: %s", name, err)
+	}
+
+	return info.Path(), nil
+}
+
+// RemoveVolume deletes the named volume's data and metadata
+func RemoveVolume(name string) error {
+	info, err := LoadVolumeInfo(name)
+
+	if err != nil {
+		return err
+	}
+
+	drv, err := info.driver()
+
+	if err != nil {
+		return err
+	}
+
+	if err := drv.Remove(info); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(info.Path()); err != nil {
+		return err
+	}
+
+	os.Remove(volumeInfoPath(name))
+
+	return nil
+}
@@ -0,0 +1,428 @@
+package env
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vektra/container/utils"
+)
+
+// A volume backup is a gzipped tar holding the volume metadata first, then
+// the volume's files under data/ and last the sha256 of every regular file.
+const (
+	backupVersion       = 1
+	backupInfoName      = "volume.json"
+	backupDataDir       = "data/"
+	backupChecksumsName = "checksums"
+)
+
+type volumeBackup struct {
+	Version int
+	Created time.Time
+	Volume  *VolumeInfo
+}
+
+// The options a copy of the volume can be created with. A host directory
+// behind a local volume is not copied along, the copy gets its own data.
+func portableOptions(info *VolumeInfo) map[string]string {
+	var opts map[string]string
+
+	for k, v := range info.Options {
+		if info.Driver == "local" && k == "path" {
+			continue
+		}
+
+		if opts == nil {
+			opts = map[string]string{}
+		}
+
+		opts[k] = v
+	}
+
+	return opts
+}
+
+func writeBackupFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(data)
+	return err
+}
+
+// Turn the ./foo names tar gives entries into foo
+func cleanTarName(name string) string {
+	name = strings.TrimPrefix(name, "./")
+
+	if name == "." {
+		return ""
+	}
+
+	return name
+}
+
+// Backup writes the volume's metadata and files to w as a gzipped tar
+func (info *VolumeInfo) Backup(w io.Writer) error {
+	if _, err := PrepareVolume(info.Name); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(&volumeBackup{Version: backupVersion, Created: time.Now(), Volume: info})
+
+	if err != nil {
+		return err
+	}
+
+	data, err := utils.Tar(info.Path(), utils.Uncompressed)
+
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeBackupFile(tw, backupInfoName, meta); err != nil {
+		return err
+	}
+
+	var sums bytes.Buffer
+
+	tr := tar.NewReader(data)
+
+	for {
+		hdr, err := tr.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = backupDataDir + cleanTarName(hdr.Name)
+
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = backupDataDir + cleanTarName(hdr.Linkname)
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			h := sha256.New()
+
+			if _, err := io.Copy(io.MultiWriter(tw, h), tr); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(&sums, "sha256:%s  %s\n", hex.EncodeToString(h.Sum(nil)), hdr.Name)
+		}
+	}
+
+	if err := writeBackupFile(tw, backupChecksumsName, sums.Bytes()); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "  ", 2)
+
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "sha256:") {
+			return nil, fmt.Errorf("Malformed checksum line: %s", scanner.Text())
+		}
+
+		sums[parts[1]] = parts[0]
+	}
+
+	return sums, scanner.Err()
+}
+
+// Unpack the data/ entries of a backup into dir, checking every file
+// against the checksums stored at the end.
+func unpackBackupData(tr *tar.Reader, dir string) error {
+	pr, pw := io.Pipe()
+
+	untar := utils.Go(func() error {
+		err := utils.Untar(pr, dir)
+		pr.CloseWithError(err)
+		return err
+	})
+
+	err := copyBackupData(tr, pw)
+	pw.CloseWithError(err)
+
+	if uerr := <-untar; err == nil {
+		err = uerr
+	}
+
+	return err
+}
+
+func copyBackupData(tr *tar.Reader, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	got := map[string]string{}
+	var expected map[string]string
+
+	for {
+		hdr, err := tr.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if hdr.Name == backupChecksumsName {
+			data, err := ioutil.ReadAll(tr)
+
+			if err != nil {
+				return err
+			}
+
+			if expected, err = parseChecksums(data); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !strings.HasPrefix(hdr.Name, backupDataDir) {
+			return fmt.Errorf("Unexpected entry in volume backup: %s", hdr.Name)
+		}
+
+		name := hdr.Name
+
+		hdr.Name = "./" + strings.TrimPrefix(name, backupDataDir)
+
+		if hdr.Typeflag == tar.TypeLink {
+			hdr.Linkname = "./" + strings.TrimPrefix(hdr.Linkname, backupDataDir)
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			h := sha256.New()
+
+			if _, err := io.Copy(io.MultiWriter(tw, h), tr); err != nil {
+				return err
+			}
+
+			got[name] = "sha256:" + hex.EncodeToString(h.Sum(nil))
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if expected == nil {
+		return fmt.Errorf("The backup has no checksums, it is probably truncated")
+	}
+
+	for name, sum := range got {
+		if expected[name] != sum {
+			return fmt.Errorf("Checksum mismatch for %s", strings.TrimPrefix(name, backupDataDir))
+		}
+	}
+
+	for name := range expected {
+		if _, ok := got[name]; !ok {
+			return fmt.Errorf("File %s is missing from the backup", strings.TrimPrefix(name, backupDataDir))
+		}
+	}
+
+	return nil
+}
+
+// Replace everything in dir with the contents of staging, a directory
+// inside dir.
+func replaceVolumeData(dir, staging string) error {
+	old, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range old {
+		if p := path.Join(dir, fi.Name()); p != staging {
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	ents, err := ioutil.ReadDir(staging)
+
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range ents {
+		if err := os.Rename(path.Join(staging, fi.Name()), path.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	fi, err := os.Lstat(staging)
+
+	if err != nil {
+		return err
+	}
+
+	os.Chmod(dir, fi.Mode().Perm())
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(dir, int(st.Uid), int(st.Gid))
+	}
+
+	return nil
+}
+
+// RestoreVolume unpacks a backup written by Backup into the named volume.
+// A missing volume is created with the driver, options and labels of the
+// one backed up. The contents of an existing volume are only replaced once
+// every file in the backup has checked out.
+func RestoreVolume(r io.Reader, name string) (*VolumeInfo, error) {
+	gz, err := gzip.NewReader(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Not a volume backup: %s", err)
+	}
+
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+
+	if err != nil || hdr.Name != backupInfoName {
+		return nil, fmt.Errorf("Not a volume backup, %s is missing", backupInfoName)
+	}
+
+	var meta volumeBackup
+
+	if err := json.NewDecoder(tr).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("Invalid volume backup metadata: %s", err)
+	}
+
+	if meta.Version != backupVersion || meta.Volume == nil {
+		return nil, fmt.Errorf("Unsupported volume backup version %d", meta.Version)
+	}
+
+	info, err := LoadVolumeInfo(name)
+	created := false
+
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		info, err = CreateVolume(name, meta.Volume.Driver, portableOptions(meta.Volume), meta.Volume.Labels)
+
+		if err != nil {
+			return nil, err
+		}
+
+		created = true
+	}
+
+	fail := func(err error) (*VolumeInfo, error) {
+		if created {
+			RemoveVolume(name)
+		}
+		return nil, err
+	}
+
+	if _, err := PrepareVolume(name); err != nil {
+		return fail(err)
+	}
+
+	staging, err := ioutil.TempDir(info.Path(), ".restore-")
+
+	if err != nil {
+		return fail(err)
+	}
+
+	defer os.RemoveAll(staging)
+
+	if err := unpackBackupData(tr, staging); err != nil {
+		return fail(err)
+	}
+
+	if err := replaceVolumeData(info.Path(), staging); err != nil {
+		return fail(err)
+	}
+
+	return info, nil
+}
+
+// CloneVolume creates the volume dst as a copy of src, using the same
+// driver, options and labels.
+func CloneVolume(src, dst string) (*VolumeInfo, error) {
+	from, err := LoadVolumeInfo(src)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := PrepareVolume(src); err != nil {
+		return nil, err
+	}
+
+	to, err := CreateVolume(dst, from.Driver, portableOptions(from), from.Labels)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := PrepareVolume(dst); err != nil {
+		RemoveVolume(dst)
+		return nil, err
+	}
+
+	if err := utils.TarUntar(from.Path(), nil, to.Path()); err != nil {
+		RemoveVolume(dst)
+		return nil, err
+	}
+
+	return to, nil
+}
@@ -0,0 +1,304 @@
+package env
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/vektra/container/utils"
+)
+
+// VolumeDriver provides the storage behind a named volume. Whatever the
+// backing, the data is made available at the volume's Path.
+type VolumeDriver interface {
+	// Check the options before anything is created
+	Validate(info *VolumeInfo) error
+	// Set up the storage for a new volume
+	Create(info *VolumeInfo) error
+	// Make sure the data is available at Path, called before each use
+	Mount(info *VolumeInfo) error
+	// Release the storage, Path itself is removed afterwards
+	Remove(info *VolumeInfo) error
+}
+
+var volumeDrivers = map[string]VolumeDriver{
+	"local": localDriver{},
+	"loop":  loopDriver{},
+	"tmpfs": tmpfsDriver{},
+}
+
+// VolumeDrivers returns the names of the available volume drivers
+func VolumeDrivers() []string {
+	return []string{"local", "loop", "tmpfs"}
+}
+
+func checkOptions(info *VolumeInfo, allowed ...string) error {
+	for key := range info.Options {
+		ok := false
+
+		for _, a := range allowed {
+			if key == a {
+				ok = true
+			}
+		}
+
+		if !ok {
+			return fmt.Errorf("Unknown option '%s' for the %s driver", key, info.Driver)
+		}
+
+		if strings.Contains(info.Options[key], ",") {
+			return fmt.Errorf("Invalid value for option '%s': %s", key, info.Options[key])
+		}
+	}
+
+	return nil
+}
+
+// Reports whether something is mounted at p. Unlike Mounted this also
+// catches bind mounts from the same filesystem.
+func isMountpoint(p string) bool {
+	data, err := ioutil.ReadFile("/proc/self/mounts")
+
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) < 2 {
+			continue
+		}
+
+		// Spaces and friends are escaped as octal, like \040
+		mnt := fields[1]
+
+		for strings.Contains(mnt, "\\") {
+			i := strings.Index(mnt, "\\")
+
+			if i+4 > len(mnt) {
+				break
+			}
+
+			c, err := strconv.ParseUint(mnt[i+1:i+4], 8, 8)
+
+			if err != nil {
+				break
+			}
+
+			mnt = mnt[:i] + string(rune(c)) + mnt[i+4:]
+		}
+
+		if mnt == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+func unmountVolume(info *VolumeInfo) error {
+	if isMountpoint(info.Path()) {
+		if out, err := utils.RunUnchecked("umount", info.Path()); err != nil {
+			return fmt.Errorf("Unable to unmount %s: %s", info.Path(), strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
+
+// A directory under DIR/volumes, or a bind mount of the host directory
+// given as the path option.
+type localDriver struct{}
+
+func (localDriver) Validate(info *VolumeInfo) error {
+	if err := checkOptions(info, "path"); err != nil {
+		return err
+	}
+
+	if src := info.Options["path"]; src != "" {
+		if !path.IsAbs(src) {
+			return fmt.Errorf("The path option must be absolute: %s", src)
+		}
+
+		if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+			return fmt.Errorf("The path option must be an existing directory: %s", src)
+		}
+	}
+
+	return nil
+}
+
+func (localDriver) Create(info *VolumeInfo) error {
+	return nil
+}
+
+func (localDriver) Mount(info *VolumeInfo) error {
+	src := info.Options["path"]
+
+	if src == "" {
+		return os.MkdirAll(info.Path(), 0755)
+	}
+
+	if isMountpoint(info.Path()) {
+		return nil
+	}
+
+	if out, err := utils.RunUnchecked("mount", "--bind", src, info.Path()); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (localDriver) Remove(info *VolumeInfo) error {
+	// Never delete the host directory, only the bind mount of it
+	if info.Options["path"] != "" {
+		if err := unmountVolume(info); err != nil {
+			return err
+		}
+
+		return os.Remove(info.Path())
+	}
+
+	return nil
+}
+
+// An ext4 image file mounted over loopback, which caps the volume at the
+// size option.
+type loopDriver struct{}
+
+func loopImagePath(info *VolumeInfo) string {
+	return path.Join(DIR, "volume-images", info.Name+".img")
+}
+
+func (loopDriver) Validate(info *VolumeInfo) error {
+	if err := checkOptions(info, "size"); err != nil {
+		return err
+	}
+
+	if info.Options["size"] == "" {
+		return fmt.Errorf("The loop driver needs a size option, like size=1G")
+	}
+
+	if _, err := utils.RAMInBytes(info.Options["size"]); err != nil {
+		return fmt.Errorf("Invalid size '%s'", info.Options["size"])
+	}
+
+	return nil
+}
+
+func (loopDriver) Create(info *VolumeInfo) error {
+	size, _ := utils.RAMInBytes(info.Options["size"])
+	img := loopImagePath(info)
+
+	if err := os.MkdirAll(path.Dir(img), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(img, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	err = f.Truncate(size)
+	f.Close()
+
+	if err != nil {
+		os.Remove(img)
+		return err
+	}
+
+	if out, err := utils.RunUnchecked("mkfs.ext4", "-F", "-q", img); err != nil {
+		os.Remove(img)
+		return fmt.Errorf("mkfs.ext4 failed: %s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (loopDriver) Mount(info *VolumeInfo) error {
+	if isMountpoint(info.Path()) {
+		return nil
+	}
+
+	os.MkdirAll(info.Path(), 0755)
+
+	if out, err := utils.RunUnchecked("mount", "-o", "loop", loopImagePath(info), info.Path()); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (loopDriver) Remove(info *VolumeInfo) error {
+	if err := unmountVolume(info); err != nil {
+		return err
+	}
+
+	os.Remove(loopImagePath(info))
+
+	return nil
+}
+
+// A tmpfs mounted the first time the volume is used. The data lives in
+// memory and is gone after a reboot.
+type tmpfsDriver struct{}
+
+func (tmpfsDriver) Validate(info *VolumeInfo) error {
+	if err := checkOptions(info, "size", "mode"); err != nil {
+		return err
+	}
+
+	if sz := info.Options["size"]; sz != "" {
+		if _, err := utils.RAMInBytes(sz); err != nil {
+			return fmt.Errorf("Invalid size '%s'", sz)
+		}
+	}
+
+	return nil
+}
+
+func (tmpfsDriver) Create(info *VolumeInfo) error {
+	return nil
+}
+
+func (tmpfsDriver) Mount(info *VolumeInfo) error {
+	if isMountpoint(info.Path()) {
+		return nil
+	}
+
+	os.MkdirAll(info.Path(), 0755)
+
+	var opts []string
+
+	if sz := info.Options["size"]; sz != "" {
+		opts = append(opts, "size="+sz)
+	}
+
+	if mode := info.Options["mode"]; mode != "" {
+		opts = append(opts, "mode="+mode)
+	}
+
+	args := []string{"-t", "tmpfs"}
+
+	if len(opts) > 0 {
+		args = append(args, "-o", strings.Join(opts, ","))
+	}
+
+	args = append(args, "tmpfs", info.Path())
+
+	if out, err := utils.RunUnchecked("mount", args...); err != nil {
+		return fmt.Errorf("%s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (tmpfsDriver) Remove(info *VolumeInfo) error {
+	return unmountVolume(info)
+}
@@ -17,10 +17,12 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -72,30 +74,50 @@ func GenerateID() string {
 	return hex.EncodeToString(id)
 }
 
-func ExpandID(root, id string) string {
+// NoSuchIDError is returned by ExpandID when no container matches
+type NoSuchIDError struct {
+	ID string
+}
+
+func (e *NoSuchIDError) Error() string {
+	return fmt.Sprintf("No such container: %s", e.ID)
+}
+
+// ExpandID finds the container under root whose id starts with id. An
+// error is returned if none or more than one container matches.
+func ExpandID(root, id string) (string, error) {
 	dirs, err := ioutil.ReadDir(path.Join(root, "containers"))
 
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	found := false
-	res := id
+	var matches []string
 
 	for _, f := range dirs {
 		dir := f.Name()
 
-		if dir[0:len(id)] == id {
-			if found {
-				return id
-			}
+		if dir == id {
+			return dir, nil
+		}
 
-			res = dir
-			found = true
+		if strings.HasPrefix(dir, id) {
+			matches = append(matches, dir)
 		}
 	}
 
-	return res
+	switch len(matches) {
+	case 0:
+		return "", &NoSuchIDError{id}
+	case 1:
+		return matches[0], nil
+	}
+
+	for i, m := range matches {
+		matches[i] = TruncateID(m)
+	}
+
+	return "", fmt.Errorf("Ambiguous container id '%s', matches %s", id, strings.Join(matches, ", "))
 }
 
 // Go is a basic promise implementation: it wraps calls a function in a goroutine,
@@ -204,6 +226,85 @@ func HumanSize(size int64) string {
 	return fmt.Sprintf("%.4g %s", sizef, units[i])
 }
 
+// RAMInBytes parses a human-readable size like "512m" or "2G" into bytes.
+// The suffixes k, m, g and t are powers of 1024, a trailing b is ignored.
+func RAMInBytes(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	s = strings.TrimSuffix(s, "b")
+
+	var mult int64 = 1
+
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k':
+			mult = 1 << 10
+		case 'm':
+			mult = 1 << 20
+		case 'g':
+			mult = 1 << 30
+		case 't':
+			mult = 1 << 40
+		}
+
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+
+	if err != nil || n < 0 {
+		return -1, fmt.Errorf("Invalid size: '%s'", size)
+	}
+
+	return n * mult, nil
+}
+
+// TreeSize returns the number of bytes used by the files under root. Like
+// du -x it does not descend into other filesystems mounted below root, and
+// hardlinked files are only counted once.
+func TreeSize(root string) (int64, error) {
+	rootSt, err := os.Lstat(root)
+	if err != nil {
+		return 0, err
+	}
+
+	dev := rootSt.Sys().(*syscall.Stat_t).Dev
+
+	var size int64
+	seen := make(map[uint64]bool)
+
+	err = filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		st := fi.Sys().(*syscall.Stat_t)
+
+		if st.Dev != dev {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if st.Nlink > 1 && !fi.IsDir() {
+			if seen[uint64(st.Ino)] {
+				return nil
+			}
+			seen[uint64(st.Ino)] = true
+		}
+
+		size += fi.Size()
+		return nil
+	})
+
+	return size, err
+}
+
 func Trunc(s string, maxlen int) string {
 	if len(s) <= maxlen {
 		return s
@@ -482,6 +583,70 @@ func FindCgroupMountpoint(cgroupType string) (string, error) {
 	return "", fmt.Errorf("cgroup mountpoint not found for %s", cgroupType)
 }
 
+// FindCgroup2Mountpoint returns where the cgroup v2 unified hierarchy is
+// mounted.
+func FindCgroup2Mountpoint() (string, error) {
+	output, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(line, " ")
+		if len(parts) == 6 && parts[2] == "cgroup2" {
+			return parts[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("cgroup2 mountpoint not found")
+}
+
+// AtomicWriteFile writes data to a temporary file next to filename, syncs it
+// and renames it into place, so readers never see a partially written file.
+// If backup is true the previous contents are kept at filename.bak.
+func AtomicWriteFile(filename string, data []byte, perm os.FileMode, backup bool) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	f.Close()
+
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if backup {
+		bak := filename + ".bak"
+		os.Remove(bak)
+
+		if err := os.Link(filename, bak); err != nil && !os.IsNotExist(err) {
+			os.Remove(f.Name())
+			return err
+		}
+	}
+
+	if err := os.Rename(f.Name(), filename); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
 // FIXME: this is deprecated by CopyWithTar in archive.go
 func CopyDirectory(source, dest string) error {
 	if output, err := exec.Command("cp", "-ra", source, dest).CombinedOutput(); err != nil {